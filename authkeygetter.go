@@ -1,7 +1,67 @@
 package config
 
-import "github.com/CodeNamor/http/apiclient"
+import (
+	"context"
+	"time"
+
+	"github.com/CodeNamor/http/apiclient"
+)
 
 type AuthKeyGetter interface {
 	GetServiceKey(service *ServiceConfig, client apiclient.RetryClient) (string, error)
 }
+
+// ExpiringAuthKeyGetter is implemented by AuthKeyGetters that know how long the keys they return are valid for.
+// An expiresIn of 0 means the lifetime of the key is unknown.
+type ExpiringAuthKeyGetter interface {
+	AuthKeyGetter
+	GetServiceKeyWithExpiry(service *ServiceConfig, client apiclient.RetryClient) (key string, expiresIn time.Duration, err error)
+}
+
+// ContextAuthKeyGetter is implemented by AuthKeyGetters that stop retrieving a key when ctx is done
+type ContextAuthKeyGetter interface {
+	AuthKeyGetter
+	GetServiceKeyContext(ctx context.Context, service *ServiceConfig, client apiclient.RetryClient) (key string, expiresIn time.Duration, err error)
+}
+
+// getServiceKey gets the key of service from keyGetter along with its lifetime when keyGetter reports one.
+// AuthKeyGetters that do not take a context are abandoned, rather than interrupted, when ctx is done: the goroutine
+// calling them runs until they return.
+func getServiceKey(ctx context.Context, keyGetter AuthKeyGetter, service *ServiceConfig, client apiclient.RetryClient) (string, time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return "", 0, err
+	}
+	if contextGetter, ok := keyGetter.(ContextAuthKeyGetter); ok {
+		return contextGetter.GetServiceKeyContext(ctx, service, client)
+	}
+	if ctx.Done() == nil { // can never be cancelled
+		return getServiceKeyWithExpiry(keyGetter, service, client)
+	}
+
+	type result struct {
+		key       string
+		expiresIn time.Duration
+		err       error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var r result
+		r.key, r.expiresIn, r.err = getServiceKeyWithExpiry(keyGetter, service, client)
+		done <- r
+	}()
+
+	select {
+	case r := <-done:
+		return r.key, r.expiresIn, r.err
+	case <-ctx.Done():
+		return "", 0, ctx.Err()
+	}
+}
+
+func getServiceKeyWithExpiry(keyGetter AuthKeyGetter, service *ServiceConfig, client apiclient.RetryClient) (string, time.Duration, error) {
+	if expiringGetter, ok := keyGetter.(ExpiringAuthKeyGetter); ok {
+		return expiringGetter.GetServiceKeyWithExpiry(service, client)
+	}
+	key, err := keyGetter.GetServiceKey(service, client)
+	return key, 0, err
+}
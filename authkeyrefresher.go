@@ -0,0 +1,246 @@
+package config
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	cnErrors "github.com/CodeNamor/Common/errors"
+	"github.com/CodeNamor/http/apiclient"
+)
+
+// defaultRefreshRetryInterval is how long an AuthKeyRefresher waits before retrying a failed refresh
+const defaultRefreshRetryInterval = 30 * time.Second
+
+// authKeyHolder holds the current auth key of a service so it can be replaced while it is being read
+type authKeyHolder struct {
+	mu        sync.RWMutex
+	key       string
+	fetchedAt time.Time
+	expiresIn time.Duration
+}
+
+func newAuthKeyHolder(key string, expiresIn time.Duration) *authKeyHolder {
+	return &authKeyHolder{
+		key:       key,
+		fetchedAt: time.Now(),
+		expiresIn: expiresIn,
+	}
+}
+
+// get returns the key, when it was fetched and its lifetime, 0 when unknown
+func (h *authKeyHolder) get() (key string, fetchedAt time.Time, expiresIn time.Duration) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.key, h.fetchedAt, h.expiresIn
+}
+
+// set replaces the key with one fetched now
+func (h *authKeyHolder) set(key string, expiresIn time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.key = key
+	h.fetchedAt = time.Now()
+	h.expiresIn = expiresIn
+}
+
+// AuthKeyRefreshSettings control when an AuthKeyRefresher renews auth keys
+type AuthKeyRefreshSettings struct {
+	// TTL is the lifetime assumed for keys when the AuthKeyGetter does not report one,
+	// 0 leaves those keys as they are
+	TTL time.Duration
+	// RefreshBefore is how long before a key expires it is renewed, it defaults to a tenth
+	// of the key lifetime
+	RefreshBefore time.Duration
+	// RetryInterval is how long to wait before retrying a failed refresh, it defaults to 30 seconds
+	RetryInterval time.Duration
+	// OnError is called whenever refreshing the key of a service fails, the previous key is kept
+	OnError func(service *ServiceConfig, err error)
+}
+
+// AuthKeyRefresher renews the auth keys of services in the background before they expire.
+// Readers see the renewed keys through ServiceConfig.CurrentAuthKey().
+type AuthKeyRefresher struct {
+	getter   AuthKeyGetter
+	settings AuthKeyRefreshSettings
+
+	// configMu guards config, the config whose keys are renewed, and client
+	configMu sync.Mutex
+	config   *Config
+	client   apiclient.RetryClient
+	// configChanged wakes run when config is replaced
+	configChanged chan struct{}
+
+	// retryAt holds when to retry services whose last refresh failed, only used by run
+	retryAt map[string]time.Time
+
+	// ctx is cancelled by Stop, abandoning any refresh in progress
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartAuthKeyRefresher starts renewing the auth keys of the services of c that require auth, using getter and
+// c.DefaultHTTPClient. Key lifetimes come from getter when it is an ExpiringAuthKeyGetter, otherwise from
+// settings.TTL. Call Stop to end the refreshing. The refresher stays bound to c, use
+// Watcher.StartAuthKeyRefresher for a config that is reloaded.
+func StartAuthKeyRefresher(c *Config, getter AuthKeyGetter, settings AuthKeyRefreshSettings) *AuthKeyRefresher {
+	if settings.RetryInterval <= 0 {
+		settings.RetryInterval = defaultRefreshRetryInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &AuthKeyRefresher{
+		config:        c,
+		getter:        getter,
+		client:        c.DefaultHTTPClient,
+		settings:      settings,
+		configChanged: make(chan struct{}, 1),
+		retryAt:       make(map[string]time.Time),
+		ctx:           ctx,
+		cancel:        cancel,
+		done:          make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// StartAuthKeyRefresher starts renewing the auth keys of the current config of w, as the package level
+// StartAuthKeyRefresher does, and moves on to the keys of each config that replaces it. The keys of replaced
+// configs are no longer renewed.
+func (w *Watcher) StartAuthKeyRefresher(getter AuthKeyGetter, settings AuthKeyRefreshSettings) *AuthKeyRefresher {
+	r := StartAuthKeyRefresher(w.Config(), getter, settings)
+	w.OnChange(func(old, new *Config, diff Diff) {
+		r.setConfig(new)
+	})
+	return r
+}
+
+// setConfig renews the keys of c from now on
+func (r *AuthKeyRefresher) setConfig(c *Config) {
+	r.configMu.Lock()
+	r.config = c
+	r.client = c.DefaultHTTPClient
+	r.configMu.Unlock()
+
+	select {
+	case r.configChanged <- struct{}{}:
+	default: // run has yet to see the previous change
+	}
+}
+
+// currentConfig returns the config whose keys are renewed and the client to renew them with
+func (r *AuthKeyRefresher) currentConfig() (*Config, apiclient.RetryClient) {
+	r.configMu.Lock()
+	defer r.configMu.Unlock()
+	return r.config, r.client
+}
+
+// Stop ends the refreshing, abandoning any refresh in progress, and waits for the refresher to finish
+func (r *AuthKeyRefresher) Stop() {
+	r.cancel()
+	<-r.done
+}
+
+func (r *AuthKeyRefresher) run() {
+	defer close(r.done)
+
+	for {
+		wait := r.refreshDue(time.Now())
+		var timer *time.Timer
+		var due <-chan time.Time
+		if wait >= 0 { // otherwise no key will be due until the config changes
+			timer = time.NewTimer(wait)
+			due = timer.C
+		}
+
+		select {
+		case <-r.ctx.Done():
+		case <-r.configChanged:
+			// the services of the new config have fresh keys
+			r.retryAt = make(map[string]time.Time)
+		case <-due:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if r.ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// refreshDue refreshes every key that is due at now and returns how long until the next key
+// is due, or -1 when no key will ever be due
+func (r *AuthKeyRefresher) refreshDue(now time.Time) time.Duration {
+	c, client := r.currentConfig()
+	next := time.Duration(-1)
+	for name, service := range c.ServiceConfigs {
+		if !service.AuthRequired || service.currentAuthKey == nil {
+			continue
+		}
+
+		due, ok := r.dueAt(name, service.currentAuthKey)
+		if ok && !due.After(now) {
+			r.refresh(name, service, client, now)
+			due, ok = r.dueAt(name, service.currentAuthKey)
+		}
+		if !ok {
+			continue
+		}
+
+		wait := due.Sub(now)
+		if wait < 0 {
+			wait = 0
+		}
+		if next < 0 || wait < next {
+			next = wait
+		}
+	}
+	return next
+}
+
+// dueAt returns when the key of the named service should next be refreshed, ok is false when the key
+// lifetime is unknown and it is never refreshed
+func (r *AuthKeyRefresher) dueAt(name string, holder *authKeyHolder) (due time.Time, ok bool) {
+	if retry, found := r.retryAt[name]; found {
+		return retry, true
+	}
+
+	_, fetchedAt, lifetime := holder.get()
+	if lifetime <= 0 {
+		lifetime = r.settings.TTL
+	}
+	if lifetime <= 0 {
+		return time.Time{}, false
+	}
+
+	margin := r.settings.RefreshBefore
+	if margin <= 0 || margin >= lifetime {
+		margin = lifetime / 10
+	}
+	return fetchedAt.Add(lifetime - margin), true
+}
+
+func (r *AuthKeyRefresher) refresh(name string, service *ServiceConfig, client apiclient.RetryClient, now time.Time) {
+	key, expiresIn, err := getServiceKey(r.ctx, r.getter, service, client)
+	if r.ctx.Err() != nil { // stopped
+		return
+	}
+	if err == nil && key == "" {
+		err = cnErrors.New("Empty auth key for " + name)
+	}
+	if err != nil {
+		r.retryAt[name] = now.Add(r.settings.RetryInterval)
+		if r.settings.OnError != nil {
+			r.settings.OnError(service, &cnErrors.ErrorLog{
+				RootCause: "Error refreshing auth key for " + name + ":",
+				Err:       err,
+			})
+		}
+		return
+	}
+
+	delete(r.retryAt, name)
+	service.currentAuthKey.set(key, expiresIn)
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CodeNamor/http/apiclient"
+	"github.com/stretchr/testify/require"
+)
+
+// sequenceKeyGetter returns key-1, key-2, ... failing every call after failAfter when it is set
+type sequenceKeyGetter struct {
+	mu        sync.Mutex
+	calls     int
+	failAfter int
+	expiresIn time.Duration
+}
+
+func (g *sequenceKeyGetter) GetServiceKeyWithExpiry(service *ServiceConfig, client apiclient.RetryClient) (string, time.Duration, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.calls++
+	if g.failAfter > 0 && g.calls > g.failAfter {
+		return "", 0, errors.New("This is a mock error")
+	}
+	return fmt.Sprintf("key-%d", g.calls), g.expiresIn, nil
+}
+
+func (g *sequenceKeyGetter) GetServiceKey(service *ServiceConfig, client apiclient.RetryClient) (string, error) {
+	key, _, err := g.GetServiceKeyWithExpiry(service, client)
+	return key, err
+}
+
+func (g *sequenceKeyGetter) callCount() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.calls
+}
+
+// plainKeyGetter only implements AuthKeyGetter so no key lifetime is known
+type plainKeyGetter struct {
+	*sequenceKeyGetter
+}
+
+func (g plainKeyGetter) GetServiceKey(service *ServiceConfig, client apiclient.RetryClient) (string, error) {
+	return g.sequenceKeyGetter.GetServiceKey(service, client)
+}
+
+func newRefresherTestConfig(t *testing.T, getter AuthKeyGetter) *Config {
+	c, errs := NewFromBytes([]byte(optionsTestConfig), "", WithAuthKeyGetter(getter))
+	require.Empty(t, errs)
+	require.Equal(t, "key-1", c.ServiceConfigs["ABS"].CurrentAuthKey())
+	return c
+}
+
+func TestAuthKeyRefresher_RefreshesBeforeExpiry(t *testing.T) {
+	getter := &sequenceKeyGetter{expiresIn: 50 * time.Millisecond}
+	c := newRefresherTestConfig(t, getter)
+
+	refresher := StartAuthKeyRefresher(c, getter, AuthKeyRefreshSettings{RefreshBefore: 20 * time.Millisecond})
+	defer refresher.Stop()
+
+	abs := c.ServiceConfigs["ABS"]
+	require.Eventually(t, func() bool {
+		return abs.CurrentAuthKey() == "key-3"
+	}, time.Second, 5*time.Millisecond)
+	// the key loaded at startup is left in place
+	require.Equal(t, "key-1", abs.AuthKey)
+}
+
+func TestAuthKeyRefresher_TTL(t *testing.T) {
+	getter := plainKeyGetter{&sequenceKeyGetter{}}
+	c := newRefresherTestConfig(t, getter)
+
+	refresher := StartAuthKeyRefresher(c, getter, AuthKeyRefreshSettings{TTL: 30 * time.Millisecond})
+	defer refresher.Stop()
+
+	require.Eventually(t, func() bool {
+		return c.ServiceConfigs["ABS"].CurrentAuthKey() == "key-2"
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestAuthKeyRefresher_UnknownLifetimeIsNotRefreshed(t *testing.T) {
+	getter := plainKeyGetter{&sequenceKeyGetter{}}
+	c := newRefresherTestConfig(t, getter)
+
+	refresher := StartAuthKeyRefresher(c, getter, AuthKeyRefreshSettings{})
+	time.Sleep(30 * time.Millisecond)
+	refresher.Stop()
+
+	require.Equal(t, 1, getter.callCount())
+	require.Equal(t, "key-1", c.ServiceConfigs["ABS"].CurrentAuthKey())
+}
+
+func TestAuthKeyRefresher_FailuresKeepKeyAndRetry(t *testing.T) {
+	getter := &sequenceKeyGetter{expiresIn: 20 * time.Millisecond, failAfter: 1}
+	c := newRefresherTestConfig(t, getter)
+
+	var mu sync.Mutex
+	failures := []string{}
+	refresher := StartAuthKeyRefresher(c, getter, AuthKeyRefreshSettings{
+		RetryInterval: 10 * time.Millisecond,
+		OnError: func(service *ServiceConfig, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failures = append(failures, service.Name+": "+err.Error())
+		},
+	})
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(failures) >= 2
+	}, time.Second, 5*time.Millisecond)
+	refresher.Stop()
+	refresher.Stop() // stopping twice is safe
+
+	require.Equal(t, "key-1", c.ServiceConfigs["ABS"].CurrentAuthKey())
+	require.Contains(t, failures[0], "ABS: Error refreshing auth key for ABS: This is a mock error")
+}
+
+func TestServiceConfig_CurrentAuthKeyWithoutHolder(t *testing.T) {
+	service := &ServiceConfig{AuthKey: "123"}
+	require.Equal(t, "123", service.CurrentAuthKey())
+}
+
+func TestWatcher_StartAuthKeyRefresher(t *testing.T) {
+	getter := &sequenceKeyGetter{expiresIn: time.Hour}
+	configPath := newWatchedConfig(t, optionsTestConfig)
+	w, errs := NewWatcher(configPath, WatchSettings{Interval: -1}, WithAuthKeyGetter(getter))
+	require.Empty(t, errs)
+	defer w.Stop()
+
+	refresher := w.StartAuthKeyRefresher(getter, AuthKeyRefreshSettings{RefreshBefore: time.Hour - 30*time.Millisecond})
+	defer refresher.Stop()
+	old := w.Config()
+	require.Eventually(t, func() bool {
+		return old.ServiceConfigs["ABS"].CurrentAuthKey() == "key-2"
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, w.Reload())
+	abs := w.Config().ServiceConfigs["ABS"]
+	require.Eventually(t, func() bool {
+		return abs.CurrentAuthKey() != abs.AuthKey
+	}, time.Second, 5*time.Millisecond)
+	refreshed := abs.CurrentAuthKey()
+	oldKey := old.ServiceConfigs["ABS"].CurrentAuthKey()
+	require.Eventually(t, func() bool {
+		return abs.CurrentAuthKey() != refreshed
+	}, time.Second, 5*time.Millisecond)
+
+	// the keys of the replaced config are no longer renewed
+	require.Equal(t, oldKey, old.ServiceConfigs["ABS"].CurrentAuthKey())
+}
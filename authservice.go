@@ -0,0 +1,139 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/CodeNamor/Common/errors"
+	"github.com/CodeNamor/http/apiclient"
+)
+
+// authKeyRequest is the body posted to the auth service to request the key of a service
+type authKeyRequest struct {
+	Service       string
+	KeyComponent1 string
+	KeyComponent2 string
+	Euuid         string
+}
+
+// authKeyResponse is the body returned by the auth service
+type authKeyResponse struct {
+	AuthKey string
+	// ExpiresIn is the number of seconds the key is valid for, 0 when the key does not expire
+	ExpiresIn int
+}
+
+// authService retrieves service auth keys from the auth service described by AuthServiceConfig
+type authService struct {
+	config AuthServiceConfig
+}
+
+// NewAuthServiceKeyGetter creates an AuthKeyGetter that requests the key of each service from the auth service at
+// config.Url, authenticating with config.Uid and config.Pwd and sending the service AuthCredentials. Services with an
+// AuthEnvironmentVariable read their key from the environment instead. Use it with WithAuthKeyGetterFn.
+func NewAuthServiceKeyGetter(config AuthServiceConfig) AuthKeyGetter {
+	return authService{config: config}
+}
+
+// GetServiceKey returns the auth key for service, using client to call the auth service
+func (s authService) GetServiceKey(service *ServiceConfig, client apiclient.RetryClient) (string, error) {
+	key, err := s.requestServiceKey(context.Background(), service, client)
+	if err != nil {
+		return "", err
+	}
+	return key.AuthKey, nil
+}
+
+// GetServiceKeyWithExpiry returns the auth key for service along with the lifetime reported by the auth service
+func (s authService) GetServiceKeyWithExpiry(service *ServiceConfig, client apiclient.RetryClient) (string, time.Duration, error) {
+	return s.GetServiceKeyContext(context.Background(), service, client)
+}
+
+// GetServiceKeyContext returns the auth key for service along with its lifetime, abandoning the request when ctx is done
+func (s authService) GetServiceKeyContext(ctx context.Context, service *ServiceConfig, client apiclient.RetryClient) (string, time.Duration, error) {
+	key, err := s.requestServiceKey(ctx, service, client)
+	if err != nil {
+		return "", 0, err
+	}
+	return key.AuthKey, time.Duration(key.ExpiresIn) * time.Second, nil
+}
+
+func (s authService) requestServiceKey(ctx context.Context, service *ServiceConfig, client apiclient.RetryClient) (authKeyResponse, error) {
+	if service.AuthEnvironmentVariable != "" {
+		key, err := adapterService{}.getEnvironmentKey(service.AuthEnvironmentVariable)
+		return authKeyResponse{AuthKey: key}, err
+	}
+
+	if s.config.Url == "" {
+		return authKeyResponse{}, &errors.ErrorLog{
+			RootCause: "No AuthServiceConfig.Url to request the auth key from",
+			Source:    service.Name,
+			Err:       errors.New("missing auth service url"),
+		}
+	}
+
+	body, err := json.Marshal(authKeyRequest{
+		Service:       service.Name,
+		KeyComponent1: service.AuthCredentials.KeyComponent1,
+		KeyComponent2: service.AuthCredentials.KeyComponent2,
+		Euuid:         service.AuthCredentials.Euuid,
+	})
+	if err != nil {
+		return authKeyResponse{}, errors.WithErrorAndCause(err, "Error encoding auth key request")
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.Url, bytes.NewReader(body))
+	if err != nil {
+		return authKeyResponse{}, &errors.ErrorLog{
+			RootCause: "Error creating auth key request",
+			Source:    service.Name,
+			Err:       err,
+		}
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(s.config.Uid, s.config.Pwd)
+
+	response, err := client.Do(request)
+	if err != nil {
+		return authKeyResponse{}, &errors.ErrorLog{
+			RootCause: "Error calling auth service " + s.config.Url,
+			Source:    service.Name,
+			Err:       err,
+		}
+	}
+	defer response.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return authKeyResponse{}, &errors.ErrorLog{
+			RootCause: "Error reading auth service response",
+			Source:    service.Name,
+			Err:       err,
+		}
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return authKeyResponse{}, &errors.ErrorLog{
+			RootCause:  "Auth service returned status " + strconv.Itoa(response.StatusCode),
+			StatusCode: strconv.Itoa(response.StatusCode),
+			Source:     service.Name,
+			Err:        errors.New(string(responseBody)),
+		}
+	}
+
+	var key authKeyResponse
+	if err := json.Unmarshal(responseBody, &key); err != nil {
+		return authKeyResponse{}, &errors.ErrorLog{
+			RootCause: "Error decoding auth service response",
+			Source:    service.Name,
+			Err:       err,
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newAuthTestServer stands in for the auth service, returning a key built from the request for valid credentials.
+// Malformed requests get an error status, which fails the GetServiceKey call checked by the test.
+func newAuthTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uid, pwd, ok := r.BasicAuth()
+		if !ok || uid != "auth_uid" || pwd != "auth_pwd" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("bad credentials"))
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			http.Error(w, "unexpected method "+r.Method, http.StatusMethodNotAllowed)
+			return
+		}
+		var request authKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "malformed request "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		switch request.Service {
+		case "BROKEN":
+			w.Write([]byte("not json"))
+		case "EMPTY":
+			w.Write([]byte(`{"AuthKey": ""}`))
+		default:
+			json.NewEncoder(w).Encode(authKeyResponse{
+				AuthKey:   request.Service + ":" + request.KeyComponent1 + ":" + request.KeyComponent2 + ":" + request.Euuid,
+				ExpiresIn: 60,
+			})
+		}
+	}))
+}
+
+func TestAuthService_GetServiceKey(t *testing.T) {
+	server := newAuthTestServer()
+	defer server.Close()
+
+	validConfig := AuthServiceConfig{Url: server.URL, Uid: "auth_uid", Pwd: "auth_pwd"}
+	absService := &ServiceConfig{
+		Name: "ABS",
+		AuthCredentials: AuthCredentials{
+			KeyComponent1: "keyc_1",
+			KeyComponent2: "keyc_2",
+			Euuid:         "abs_euuid",
+		},
+	}
+
+	testcases := []struct {
+		name          string
+		config        AuthServiceConfig
+		service       *ServiceConfig
+		env           map[string]string
+		expectedKey   string
+		expectedError string
+	}{
+		{
+			name:        "sends credentials and returns the key",
+			config:      validConfig,
+			service:     absService,
+			expectedKey: "ABS:keyc_1:keyc_2:abs_euuid",
+		},
+		{
+			name:        "environment variable takes precedence",
+			config:      validConfig,
+			service:     &ServiceConfig{Name: "ABS", AuthEnvironmentVariable: "CONFIG_TEST_ABS_KEY"},
+			env:         map[string]string{"CONFIG_TEST_ABS_KEY": "from-env"},
+			expectedKey: "from-env",
+		},
+		{
+			name:          "missing auth service url",
+			config:        AuthServiceConfig{},
+			service:       absService,
+			expectedError: "No AuthServiceConfig.Url to request the auth key from",
+		},
+		{
+			name:          "rejected credentials",
+			config:        AuthServiceConfig{Url: server.URL, Uid: "auth_uid", Pwd: "wrong"},
+			service:       absService,
+			expectedError: "Auth service returned status 401 bad credentials StatusCode:401 Source:ABS",
+		},
+		{
+			name:          "unreachable auth service",
+			config:        AuthServiceConfig{Url: "http://127.0.0.1:1", Uid: "auth_uid", Pwd: "auth_pwd"},
+			service:       absService,
+			expectedError: "Error calling auth service http://127.0.0.1:1",
+		},
+		{
+			name:          "malformed response",
+			config:        validConfig,
+			service:       &ServiceConfig{Name: "BROKEN"},
+			expectedError: "Error decoding auth service response",
+		},
+		{
+			name:        "empty key is returned for LoadServiceAuthKeys to report",
+			config:      validConfig,
+			service:     &ServiceConfig{Name: "EMPTY"},
+			expectedKey: "",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+
+			getter := NewAuthServiceKeyGetter(tc.config)
+			key, err := getter.GetServiceKey(tc.service, http.DefaultClient)
+			if tc.expectedError != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedKey, key)
+		})
+	}
+}
+
+func TestNewWithAuthServiceKeyGetter(t *testing.T) {
+	server := newAuthTestServer()
+	defer server.Close()
+
+	data := `{
+  "AuthServiceConfig": {"Url": "` + server.URL + `", "Uid": "auth_uid", "Pwd": "auth_pwd"},
+  "ServiceConfigs": [
+    {"Name": "ABS", "AuthRequired": true, "AuthCredentials": {"KeyComponent1": "a", "KeyComponent2": "b", "Euuid": "c"}}
+  ]
+}`
+	c, errs := NewFromBytes([]byte(data), "",
+		WithAuthKeyGetterFn(NewAuthServiceKeyGetter),
+		WithRetryClientBuilder(httpClientBuilder),
+	)
+	require.Empty(t, errs)
+	require.Equal(t, "ABS:a:b:c", c.ServiceConfigs["ABS"].AuthKey)
+}
@@ -0,0 +1,34 @@
+// Command configschema writes the JSON Schema of config files, to standard output or to the file given with -o:
+//
+//	go run github.com/CodeNamor/Config/cmd/configschema -o config.schema.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	config "github.com/CodeNamor/Config"
+)
+
+func main() {
+	output := flag.String("o", "", "write the schema to this file rather than to standard output")
+	flag.Parse()
+
+	schema, err := config.JSONSchema()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error generating the config schema:", err)
+		os.Exit(1)
+	}
+	schema = append(schema, '\n')
+
+	if *output == "" {
+		_, err = os.Stdout.Write(schema)
+	} else {
+		err = os.WriteFile(*output, schema, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing the config schema:", err)
+		os.Exit(1)
+	}
+}
@@ -68,13 +68,19 @@ The config.json will have this structure:
 }
 */
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
 	"os"
 	"strings"
 
 	"github.com/CodeNamor/http/apiclient"
 	"github.com/kr/pretty"
-	log "github.com/sirupsen/logrus"
 )
 
 // Config models the configuration settings read from a config file. Any changes to this struct will potentially break
@@ -103,6 +109,13 @@ type Config struct {
 
 	// A unique identifier for the config file that backs this struct
 	Hash string
+
+	// transports are the transports of every http client built for this config
+	transports []*http.Transport
+	// files are the config files read to build this config: the config file, overlays and included files
+	files []string
+	// dir is the directory relative CABundlePath values were resolved against
+	dir string
 }
 
 // LoggingConfig holds the string representation of the logging level and the graylog URL.
@@ -149,6 +162,31 @@ const (
 	True
 )
 
+// UnmarshalJSON accepts a configFlag written either as its integer value or as a boolean, true and false being
+// decoded as True and False respectively
+func (f *configFlag) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		if b {
+			*f = True
+		} else {
+			*f = False
+		}
+		return nil
+	}
+
+	var i int
+	if err := json.Unmarshal(data, &i); err != nil {
+		return err
+	}
+	*f = configFlag(i)
+	return nil
+}
+
 // AuthServiceConfig models the credentials necessary to authenticate to a a service for getting auth keys
 type AuthServiceConfig struct {
 	Url string
@@ -161,25 +199,68 @@ type NewAuthKeyGetterFn func(AuthServiceConfig) AuthKeyGetter
 
 type clientFromConfigFn func(ClientConfig) apiclient.RetryClient
 
-// New takes a config file path and name and returns a pointer to a loaded Config
-func New(configPath string) (*Config, []error) {
-	return newConfig(&defaultConfigBuilder{}, apiclient.NewExtendedHTTPClient, newAdapterService, configPath)
+// New takes a config file path and name and returns a pointer to a loaded Config.
+// The format is selected from the file extension (.yaml, .yml, .toml, anything
+// else is JSON) unless WithFormat is given. When configPath is a directory every
+// *.json file in it is merged, in lexical order, with ServiceConfigs and
+// DatabaseConfigs merged by Name, and CABundlePath is resolved against the directory.
+func New(configPath string, opts ...Option) (*Config, []error) {
+	return NewWithContext(context.Background(), configPath, opts...)
+}
+
+// NewWithContext loads the Config from configPath as New does, fetching the service auth keys until ctx is done.
+// WithAuthKeyConcurrency fetches several keys at a time and WithAuthKeyTimeout limits how long each key may take.
+// Every service whose key could not be fetched is reported in the returned errors.
+func NewWithContext(ctx context.Context, configPath string, opts ...Option) (*Config, []error) {
+	o := newOptions(opts)
+	return newConfig(ctx, o.builder(), o.retryClientBuilder, o.authKeyGetterFn, configPath)
 }
 
-func newConfig(builder configBuilder, retryClientBuilderFn RetryClientBuilderFn, authKeyService NewAuthKeyGetterFn, configPath string) (*Config, []error) {
-	var err error
+// NewFromFS loads the Config from configPath in fsys. Overlays and CA bundles are also read from fsys, with
+// CABundlePath resolved relative to configPath, so a default config can be embedded in the binary with embed.FS.
+func NewFromFS(fsys fs.FS, configPath string, opts ...Option) (*Config, []error) {
+	o := newOptions(opts)
+	o.fsys = fsys
+	return newConfig(context.Background(), o.builder(), o.retryClientBuilder, o.authKeyGetterFn, configPath)
+}
 
+// NewFromReader loads the Config from configData without reading a config file, running the same pipeline as New.
+// Relative CABundlePath values are resolved against baseDir. The data is decoded as JSON unless WithFormat is given.
+func NewFromReader(configData io.Reader, baseDir string, opts ...Option) (*Config, []error) {
+	o := newOptions(opts)
+	o.baseDir = baseDir
+	return buildConfig(context.Background(), o.builder(), o.retryClientBuilder, o.authKeyGetterFn, configData)
+}
+
+// NewFromBytes loads the Config from configData as NewFromReader does
+func NewFromBytes(configData []byte, baseDir string, opts ...Option) (*Config, []error) {
+	return NewFromReader(bytes.NewReader(configData), baseDir, opts...)
+}
+
+func newConfig(ctx context.Context, builder configBuilder, retryClientBuilderFn RetryClientBuilderFn, authKeyService NewAuthKeyGetterFn, configPath string) (*Config, []error) {
 	configFile, err := builder.Load(configPath)
 	if err != nil {
 		return nil, []error{err}
 	}
 	defer configFile.Close()
 
-	err = builder.Read(configFile)
-	if err != nil {
+	return buildConfig(ctx, builder, retryClientBuilderFn, authKeyService, configFile)
+}
+
+// buildConfig reads the config data then builds the http clients and loads the service auth keys
+func buildConfig(ctx context.Context, builder configBuilder, retryClientBuilderFn RetryClientBuilderFn, authKeyService NewAuthKeyGetterFn, configData io.Reader) (*Config, []error) {
+	err := builder.Read(configData)
+	var readErrs readErrors
+	if errors.As(err, &readErrs) {
+		return nil, readErrs
+	} else if err != nil {
 		return nil, []error{err}
 	}
 
+	if errs := builder.Validate(); len(errs) != 0 {
+		return nil, errs
+	}
+
 	buildClientFn, err := builder.InitClientFn(retryClientBuilderFn)
 	if err != nil {
 		return nil, []error{err}
@@ -191,11 +272,11 @@ func newConfig(builder configBuilder, retryClientBuilderFn RetryClientBuilderFn,
 	// merge service Overrides with defaults
 	for _, serviceConfig := range builder.GetConfig().ServiceConfigs {
 		// log the merged settings that will govern each ServiceConfig
-		log.Info(pretty.Sprintf("ServiceName: %v ServiceConfigs.MergedComponentConfigs: %v", serviceConfig.Name, serviceConfig.MergedComponentConfigs()))
+		builder.GetLogger().Info(pretty.Sprintf("ServiceName: %v ServiceConfigs.MergedComponentConfigs: %v", serviceConfig.Name, serviceConfig.MergedComponentConfigs()))
 	}
 
 	authService := authKeyService(builder.GetConfig().AuthServiceConfig)
-	errs := builder.LoadServiceAuthKeys(authService, builder.GetConfig().DefaultHTTPClient)
+	errs := builder.LoadServiceAuthKeysContext(ctx, authService, builder.GetConfig().DefaultHTTPClient)
 	if len(errs) != 0 {
 		return nil, errs
 	}
@@ -219,14 +300,17 @@ func (c *Config) GetServiceConfig(name string) (service *ServiceConfig, err erro
 	return
 }
 
-// GetDatabaseConfig returns a service configuration by name
+// GetDatabaseConfig returns a copy of a database configuration by name, with the Password read from the
+// AuthEnvironmentVariable when AuthRequired. The config itself is not modified so it is safe for concurrent use.
 func (c *Config) GetDatabaseConfig(name string) (database *DatabaseConfig, err error) {
-	database, ok := c.DatabaseConfigs[name]
+	configured, ok := c.DatabaseConfigs[name]
 
 	if ok {
-		if database.AuthRequired {
-			database.Password = os.Getenv(database.AuthEnvironmentVariable)
+		databaseCopy := *configured
+		if databaseCopy.AuthRequired {
+			databaseCopy.Password = os.Getenv(databaseCopy.AuthEnvironmentVariable)
 		}
+		database = &databaseCopy
 	} else {
 		err = fmt.Errorf("unable to locate database configuration for %v", name)
 	}
@@ -234,6 +318,14 @@ func (c *Config) GetDatabaseConfig(name string) (database *DatabaseConfig, err e
 	return
 }
 
+// CloseIdleConnections closes the idle connections of every http client built for the config. Requests in progress
+// are not affected, once a config is no longer used this releases the connections it kept open.
+func (c *Config) CloseIdleConnections() {
+	for _, transport := range c.transports {
+		transport.CloseIdleConnections()
+	}
+}
+
 // IsLocal returns true if the configuration is for a local machine
 func (c *Config) IsLocal() bool {
 	return strings.ToLower(c.Env) == "local"
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+const fsTestConfig = `{
+  "Env": "UnitTest",
+  "Port": 8000,
+  "DefaultComponentConfigs": {
+    "Client": {
+      "Timeout": 10,
+      "CABundlePath": "certs/cabundle.pem"
+    }
+  },
+  "ServiceConfigs": [
+    {
+      "Name": "ABS",
+      "Url": "https://some.url.com"
+    }
+  ]
+}`
+
+func newTestFS(t *testing.T) fstest.MapFS {
+	caBundle, err := os.ReadFile("testdata/example_cabundle.pem")
+	require.NoError(t, err)
+
+	return fstest.MapFS{
+		"conf/config.json":        {Data: []byte(fsTestConfig)},
+		"conf/config.local.yaml":  {Data: []byte("Port: 9000\n")},
+		"conf/certs/cabundle.pem": {Data: caBundle},
+	}
+}
+
+func TestNewFromFS(t *testing.T) {
+	fsys := newTestFS(t)
+
+	c, errs := NewFromFS(fsys, "conf/config.json", WithOverlays("conf/config.local.yaml"))
+	require.Empty(t, errs)
+	require.Equal(t, 9000, c.Port)
+	require.NotNil(t, c.DefaultHTTPClient)
+
+	abs, err := c.GetServiceConfig("ABS")
+	require.NoError(t, err)
+	require.NotNil(t, abs.HTTPClient)
+}
+
+func TestNewFromFSErrors(t *testing.T) {
+	testcases := []struct {
+		name          string
+		configPath    string
+		opts          []Option
+		removePath    string
+		expectedError string
+	}{
+		{
+			name:          "missing config file",
+			configPath:    "conf/nope.json",
+			expectedError: "Error opening config file conf/nope.json",
+		},
+		{
+			name:          "missing overlay",
+			configPath:    "conf/config.json",
+			opts:          []Option{WithOverlays("conf/nope.json")},
+			expectedError: "Error reading overlay config file conf/nope.json",
+		},
+		{
+			name:          "ca bundle is only read from the filesystem",
+			configPath:    "conf/config.json",
+			removePath:    "conf/certs/cabundle.pem",
+			expectedError: "Error reading cert file conf/certs/cabundle.pem",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := newTestFS(t)
+			delete(fsys, tc.removePath)
+
+			c, errs := NewFromFS(fsys, tc.configPath, tc.opts...)
+			require.Nil(t, c)
+			require.Len(t, errs, 1)
+			require.Contains(t, errs[0].Error(), tc.expectedError)
+		})
+	}
+}
+
+func TestLoadCertPoolFS(t *testing.T) {
+	fsys := newTestFS(t)
+
+	pool, err := LoadCertPoolFS(fsys, "conf/certs/cabundle.pem")
+	require.NoError(t, err)
+	require.NotNil(t, pool)
+
+	_, err = LoadCertPoolFS(fsys, "conf/config.json")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "error appending certs from cert file")
+}
+
+func TestNewFromBytes(t *testing.T) {
+	testcases := []struct {
+		name          string
+		data          string
+		baseDir       string
+		opts          []Option
+		expectedPort  int
+		expectedError string
+	}{
+		{
+			name:         "json resolved against base dir",
+			data:         `{"Port": 8000, "DefaultComponentConfigs": {"Client": {"CABundlePath": "example_cabundle.pem"}}}`,
+			baseDir:      "testdata",
+			expectedPort: 8000,
+		},
+		{
+			name:         "yaml with explicit format",
+			data:         "Port: 9000\nDefaultComponentConfigs:\n  Client:\n    CABundlePath: testdata/example_cabundle.pem\n",
+			opts:         []Option{WithFormat(FormatYAML)},
+			expectedPort: 9000,
+		},
+		{
+			name:          "ca bundle missing from base dir",
+			data:          `{"Port": 8000, "DefaultComponentConfigs": {"Client": {"CABundlePath": "example_cabundle.pem"}}}`,
+			baseDir:       "not_a_dir",
+			expectedError: "Error reading cert file not_a_dir/example_cabundle.pem",
+		},
+		{
+			name:          "invalid data",
+			data:          `{"OopsBadField": 123}`,
+			expectedError: "Error decoding config data json: unknown field",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, errs := NewFromBytes([]byte(tc.data), tc.baseDir, tc.opts...)
+			if tc.expectedError != "" {
+				require.Nil(t, c)
+				require.Len(t, errs, 1)
+				require.Contains(t, errs[0].Error(), tc.expectedError)
+				return
+			}
+			require.Empty(t, errs)
+			require.Equal(t, tc.expectedPort, c.Port)
+			require.NotNil(t, c.DefaultHTTPClient)
+		})
+	}
+}
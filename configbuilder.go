@@ -2,6 +2,7 @@ package config
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"crypto/tls"
 	"crypto/x509"
@@ -9,10 +10,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/imdario/mergo"
@@ -25,17 +30,54 @@ import (
 )
 
 type configBuilder interface {
-	Load(string) (*os.File, error)
+	Load(string) (io.ReadCloser, error)
 	Read(io.Reader) error
+	Validate() []error
 	InitClientFn(RetryClientBuilderFn) (clientFromConfigFn, error)
-	LoadServiceAuthKeys(AuthKeyGetter, apiclient.RetryClient) []error
+	LoadServiceAuthKeysContext(context.Context, AuthKeyGetter, apiclient.RetryClient) []error
 	GetConfig() *Config
 	GetConfigPath() string
+	GetLogger() *log.Logger
 }
 
 type defaultConfigBuilder struct {
 	config     *Config
 	configPath string
+	format     Format
+
+	// overlayPaths are config files deep merged, in order, over the config file
+	overlayPaths []string
+	// envPrefix enables overriding config values with environment variables starting with the prefix
+	envPrefix string
+	// flags are the command-line overrides applied after the environment overrides
+	flags *Flags
+	// interpolation resolves ${...} expressions in string values once the overrides are applied
+	interpolation bool
+	// fsys is the filesystem config files and CA bundles are read from, nil reads from the OS filesystem
+	fsys fs.FS
+	// baseDir when set is used instead of the config file directory to resolve CABundlePath
+	baseDir string
+	// transport when set is cloned as the base of every http client transport
+	transport *http.Transport
+	// logger receives the builder logging, nil logs to the logrus standard logger
+	logger *log.Logger
+	// authKeyWorkers is the number of auth keys fetched at a time, less than 1 fetches them one at a time
+	authKeyWorkers int
+	// authKeyTimeout when set limits how long fetching each auth key may take
+	authKeyTimeout time.Duration
+	// configIsDir is set when configPath is a directory, fragments are then the config files found in it
+	configIsDir bool
+	fragments   []configSource
+	// lenientNames keeps the last list entry for a Name, logging a warning, rather than failing the load
+	lenientNames bool
+	// validation checks the loaded config against the rules of validateConfig
+	validation bool
+	// customAuthKeys is set when a getter given as an option supplies the auth keys, which may not come from the
+	// service configs
+	customAuthKeys bool
+	// schemaValidation checks the config sources, with their includes expanded, against the config schema before they
+	// are decoded
+	schemaValidation bool
 }
 
 func (b *defaultConfigBuilder) GetConfig() *Config {
@@ -46,9 +88,67 @@ func (b *defaultConfigBuilder) GetConfigPath() string {
 	return b.configPath
 }
 
+// GetLogger returns the logger used while building the config
+func (b *defaultConfigBuilder) GetLogger() *log.Logger {
+	if b.logger == nil {
+		return log.StandardLogger()
+	}
+	return b.logger
+}
+
+// configDir returns the directory relative CABundlePath values are resolved against,
+// the explicit baseDir if one was given otherwise the directory of the config file
+func (b *defaultConfigBuilder) configDir() string {
+	if b.baseDir != "" {
+		return b.baseDir
+	}
+	if b.configIsDir {
+		return b.configPath
+	}
+	return path.Dir(b.configPath)
+}
+
+// open opens a file from the builder filesystem
+func (b *defaultConfigBuilder) open(path string) (io.ReadCloser, error) {
+	if b.fsys != nil {
+		return b.fsys.Open(path)
+	}
+	return os.Open(path)
+}
+
+// stat describes a file of the builder filesystem
+func (b *defaultConfigBuilder) stat(path string) (fs.FileInfo, error) {
+	if b.fsys != nil {
+		return fs.Stat(b.fsys, path)
+	}
+	return os.Stat(path)
+}
+
+// readFile reads a file from the builder filesystem
+func (b *defaultConfigBuilder) readFile(path string) ([]byte, error) {
+	if b.fsys != nil {
+		return fs.ReadFile(b.fsys, path)
+	}
+	return ioutil.ReadFile(path)
+}
+
+// readFileFn reads the whole of the named file
+type readFileFn func(string) ([]byte, error)
+
 // LoadCertPool reads certificates from a CA bundle file and loads them into a certificate pool
 func LoadCertPool(caBundlePath string) (*x509.CertPool, error) {
-	certData, err := ioutil.ReadFile(caBundlePath)
+	return loadCertPool(ioutil.ReadFile, caBundlePath)
+}
+
+// LoadCertPoolFS reads certificates from a CA bundle file in fsys and loads them into a certificate pool
+func LoadCertPoolFS(fsys fs.FS, caBundlePath string) (*x509.CertPool, error) {
+	return loadCertPool(func(name string) ([]byte, error) {
+		return fs.ReadFile(fsys, name)
+	}, caBundlePath)
+}
+
+func loadCertPool(readFile readFileFn, caBundlePath string) (*x509.CertPool, error) {
+	certData, err := readFile(caBundlePath)
 	if err != nil {
 		errMsg := &cnErrors.ErrorLog{
 			RootCause: "Error reading cert file " + caBundlePath,
@@ -76,14 +176,14 @@ type bundleMap map[string]*x509.CertPool
 // loadCABundle checks bundleMap to see if a caBundle exists and uses it if found
 // otherwise it loads the cleanedCABundlePath and stores it by original caBundlePath
 // so it can be found later
-func loadCABundle(bundleMap bundleMap, cleanedCABundlePath string, caBundlePath string) error {
+func loadCABundle(readFile readFileFn, bundleMap bundleMap, cleanedCABundlePath string, caBundlePath string) error {
 	if caBundlePath == "" { // nothing to load
 		return nil
 	}
 
 	_, ok := bundleMap[caBundlePath]
 	if !ok { // not yet loaded
-		certPool, err := LoadCertPool(cleanedCABundlePath)
+		certPool, err := loadCertPool(readFile, cleanedCABundlePath)
 		if err != nil {
 			return err
 		}
@@ -98,34 +198,41 @@ func loadCABundle(bundleMap bundleMap, cleanedCABundlePath string, caBundlePath
 func (b *defaultConfigBuilder) InitClientFn(rbfn RetryClientBuilderFn) (clientFromConfigFn, error) {
 	DefaultHTTPClientConfig := b.config.DefaultComponentConfigs.Client
 	mapCertPools := make(bundleMap)
-	defCleanedCAPath := resolveCAPath(b.GetConfigPath(), DefaultHTTPClientConfig.CABundlePath)
-	err := loadCABundle(mapCertPools, defCleanedCAPath, DefaultHTTPClientConfig.CABundlePath)
+	defCleanedCAPath := resolveCAPathFromDir(b.configDir(), DefaultHTTPClientConfig.CABundlePath)
+	err := loadCABundle(b.readFile, mapCertPools, defCleanedCAPath, DefaultHTTPClientConfig.CABundlePath)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, serviceConfig := range b.config.ServiceConfigs {
 		caBundlePath := serviceConfig.MergedComponentConfigs().Client.CABundlePath
-		cleanedCAPath := resolveCAPath(b.GetConfigPath(), caBundlePath)
-		err = loadCABundle(mapCertPools, cleanedCAPath, caBundlePath)
+		cleanedCAPath := resolveCAPathFromDir(b.configDir(), caBundlePath)
+		err = loadCABundle(b.readFile, mapCertPools, cleanedCAPath, caBundlePath)
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	buildClientFn := func(mc ClientConfig) apiclient.RetryClient {
-		return createHTTPClient(mc, mapCertPools, rbfn)
+		client, transport := createHTTPClient(mc, mapCertPools, rbfn, b.transport)
+		b.config.transports = append(b.config.transports, transport)
+		return client
 	}
 
 	return buildClientFn, nil
 }
 
-// Load loads the config data
-func (b *defaultConfigBuilder) Load(path string) (*os.File, error) {
-	log.Trace("Loading config file: " + path)
+// Load loads the config data. When path is a directory every *.json file in it is loaded, in lexical order, to be
+// merged by Read as overlays are.
+func (b *defaultConfigBuilder) Load(path string) (io.ReadCloser, error) {
+	b.GetLogger().Trace("Loading config file: " + path)
 	b.configPath = path
 
-	file, err := os.Open(path)
+	if info, err := b.stat(path); err == nil && info.IsDir() {
+		return b.loadDir(path)
+	}
+
+	file, err := b.open(path)
 	if err != nil {
 		msg := &cnErrors.ErrorLog{
 			RootCause: "Error opening config file " + path,
@@ -137,16 +244,118 @@ func (b *defaultConfigBuilder) Load(path string) (*os.File, error) {
 	return file, err
 }
 
-// Read parses the JSON data and creates mergedComponentConfigs
+// loadDir reads the config files of the directory dir into fragments, the returned reader is empty
+func (b *defaultConfigBuilder) loadDir(dir string) (io.ReadCloser, error) {
+	files, err := b.glob(path.Join(dir, "*.json"))
+	if err == nil && len(files) == 0 {
+		err = errors.New("no *.json files")
+	}
+	if err != nil {
+		return nil, &cnErrors.ErrorLog{
+			RootCause: "Error listing config files in directory " + dir,
+			Err:       err,
+		}
+	}
+
+	b.configIsDir = true
+	b.fragments = make([]configSource, 0, len(files))
+	for _, file := range files {
+		b.GetLogger().Trace("Loading config file: " + file)
+		data, err := b.readFile(file)
+		if err != nil {
+			return nil, &cnErrors.ErrorLog{
+				RootCause: "Error reading config file " + file,
+				Err:       err,
+			}
+		}
+		b.fragments = append(b.fragments, configSource{
+			path:   file,
+			format: resolveFormat(b.format, file),
+			data:   data,
+		})
+	}
+	return ioutil.NopCloser(bytes.NewReader(nil)), nil
+}
+
+// Read parses the config data, in the format given to the builder or
+// selected from the config path, and creates mergedComponentConfigs
 // which are the merge of DefaultComponentConfigs and
 // serviceConfig.ComponentConfigOverrides
 func (b *defaultConfigBuilder) Read(configData io.Reader) error {
-	log.Trace("Reading config data")
+	b.GetLogger().Trace("Reading config data")
+
+	var sources []configSource
+	if b.configIsDir {
+		sources = append(sources, b.fragments...)
+	} else {
+		theBytes, readerError := ioutil.ReadAll(configData)
+		if readerError != nil {
+			return cnErrors.WithErrorAndCause(readerError, "Error reading config data")
+		}
+		sources = append(sources, configSource{
+			path:   b.configPath,
+			format: resolveFormat(b.format, b.configPath),
+			data:   theBytes,
+		})
+	}
+
+	// includes are resolved relative to the config directory for the config files and to their own
+	// directory for overlays
+	dirs := make([]string, 0, len(sources)+len(b.overlayPaths))
+	files := make([]string, 0, len(sources)+len(b.overlayPaths))
+	for _, src := range sources {
+		dirs = append(dirs, b.configDir())
+		if src.path != "" {
+			files = append(files, src.path)
+		}
+	}
+	for _, overlayPath := range b.overlayPaths {
+		dirs = append(dirs, path.Dir(overlayPath))
+		files = append(files, overlayPath)
+	}
+
+	overlays, err := b.readOverlays()
+	if err != nil {
+		return err
+	}
+	sources = append(sources, overlays...)
+
+	sources, includedFiles, err := b.expandIncludes(sources, dirs)
+	if err != nil {
+		return err
+	}
+
+	if b.schemaValidation {
+		if errs := validateSchema(sources); len(errs) != 0 {
+			return readErrors(errs)
+		}
+	}
 
-	configuration, errs := buildInitialConfig(configData)
+	configuration, errs := b.buildInitialConfig(sources)
 	if errs != nil {
 		return errs
 	}
+	configuration.files = append(files, includedFiles...)
+	configuration.dir = b.configDir()
+
+	ignoredVariables, envError := applyEnvOverrides(configuration, b.envPrefix, os.Environ())
+	for _, message := range ignoredVariables {
+		b.GetLogger().Warn(message)
+	}
+	if envError != nil {
+		return cnErrors.WithErrorAndCause(envError, "Error applying environment overrides")
+	}
+
+	flagError := b.flags.apply(configuration)
+	if flagError != nil {
+		return cnErrors.WithErrorAndCause(flagError, "Error applying flag overrides")
+	}
+
+	if b.interpolation {
+		if err := interpolate(configuration, os.LookupEnv); err != nil {
+			return cnErrors.WithErrorAndCause(err, "Error interpolating config values")
+		}
+	}
 
 	// now populate mergedComponentConfigs using serviceConfig and defaults
 	mergeError := mergeComponentConfigsForAllServices(configuration) // updates in place
@@ -159,51 +368,188 @@ func (b *defaultConfigBuilder) Read(configData io.Reader) error {
 	return nil
 }
 
-func buildInitialConfig(configData io.Reader) (*Config, error) {
-	theBytes, readerError := ioutil.ReadAll(configData)
-	if readerError != nil {
-		return nil, cnErrors.WithErrorAndCause(readerError, "Error reading config data")
+// readOverlays reads the overlay config files in the order they were given
+func (b *defaultConfigBuilder) readOverlays() ([]configSource, error) {
+	sources := make([]configSource, 0, len(b.overlayPaths))
+	for _, overlayPath := range b.overlayPaths {
+		b.GetLogger().Trace("Reading overlay config file: " + overlayPath)
+		data, err := b.readFile(overlayPath)
+		if err != nil {
+			return nil, &cnErrors.ErrorLog{
+				RootCause: "Error reading overlay config file " + overlayPath,
+				Err:       err,
+			}
+		}
+		sources = append(sources, configSource{
+			path:   overlayPath,
+			format: resolveFormat(b.format, overlayPath),
+			data:   data,
+		})
+	}
+	return sources, nil
+}
+
+// readErrors are the problems found in the config sources, returned by Read as a
+// single error and reported one by one in the []error result of New
+type readErrors []error
+
+func (errs readErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// buildInitialConfig decodes the config sources into a Config. When there is
+// more than one source, each later source is deep merged over the earlier
+// ones before decoding. The Hash covers the content of every source, leaving
+// out the comments of JSON sources.
+func (b *defaultConfigBuilder) buildInitialConfig(sources []configSource) (*Config, error) {
+	jsonBytes, err := sourcesToJSON(sources)
+	if err != nil {
+		return nil, err
 	}
 
-	byteReader := bytes.NewReader(theBytes)
+	byteReader := bytes.NewReader(jsonBytes)
 
 	c := &Config{}
 	decoder := json.NewDecoder(byteReader)
 	decoder.DisallowUnknownFields()
 	decoderError := decoder.Decode(&c)
 	if decoderError != nil {
-		return nil, cnErrors.WithErrorAndCause(decoderError, "Error decoding config data")
+		return nil, cnErrors.WithErrorAndCause(locateDecodeError(sources, decoderError), "Error decoding config data")
+	}
+
+	if err := b.checkNames(sources, jsonBytes); err != nil {
+		return nil, err
+	}
+	for _, message := range ignoredFields(sources) {
+		b.GetLogger().Warn(message)
 	}
-	c.Hash = fmt.Sprintf("%x", md5.Sum(theBytes))
+
+	hash := md5.New()
+	for _, src := range sources {
+		data := src.data
+		if src.format == FormatJSON || src.format == FormatAuto {
+			// decoding succeeded so the data has no unterminated comment
+			data, _ = stripJSONC(data, false)
+		}
+		if len(sources) > 1 { // frame each source so moving content from one to another changes the hash
+			fmt.Fprintf(hash, "%d:", len(data))
+		}
+		hash.Write(data)
+	}
+	c.Hash = fmt.Sprintf("%x", hash.Sum(nil))
 
 	return c, nil
 }
 
+// sourcesToJSON converts the sources into a single JSON document
+func sourcesToJSON(sources []configSource) ([]byte, error) {
+	if len(sources) == 1 {
+		jsonBytes, err := toJSON(sources[0].data, sources[0].format)
+		if err != nil {
+			return nil, cnErrors.WithErrorAndCause(err, "Error converting "+sources[0].format.String()+" config data")
+		}
+		return jsonBytes, nil
+	}
+
+	var merged interface{}
+	for _, src := range sources {
+		tree, err := decodeTree(src)
+		if err != nil {
+			return nil, cnErrors.WithErrorAndCause(err, "Error converting "+src.format.String()+" config data "+src.path)
+		}
+		merged = mergeTrees(merged, tree, "")
+	}
+
+	jsonBytes, err := json.Marshal(merged)
+	if err != nil {
+		return nil, cnErrors.WithErrorAndCause(err, "Error merging config data")
+	}
+	return jsonBytes, nil
+}
+
 // LoadServiceAuthKeys attempts to get an auth key from the keyGetter, using the the provided client for communication,
 // for each service config that requires auth to be used.
 func (b *defaultConfigBuilder) LoadServiceAuthKeys(keyGetter AuthKeyGetter, client apiclient.RetryClient) []error {
-	log.Trace("Loading auth keys")
-	errs := make([]error, 0)
-	var err error
+	return b.LoadServiceAuthKeysContext(context.Background(), keyGetter, client)
+}
 
+// LoadServiceAuthKeysContext gets the auth keys as LoadServiceAuthKeys does, fetching up to authKeyWorkers keys at a
+// time and giving up on each key after authKeyTimeout. Keys not yet fetched when ctx is done fail with the ctx error.
+// Errors are returned in service name order.
+func (b *defaultConfigBuilder) LoadServiceAuthKeysContext(ctx context.Context, keyGetter AuthKeyGetter, client apiclient.RetryClient) []error {
+	b.GetLogger().Trace("Loading auth keys")
+
+	names := make([]string, 0, len(b.config.ServiceConfigs))
 	for name, serviceConfig := range b.config.ServiceConfigs {
 		if serviceConfig.AuthRequired {
-			serviceConfig.AuthKey, err = keyGetter.GetServiceKey(serviceConfig, client)
-
-			if err != nil {
-				errs = append(errs, &cnErrors.ErrorLog{
-					RootCause: "Error retrieving auth key for " + name + ":",
-					Err:       err,
-				})
-			} else if serviceConfig.AuthKey == "" {
-				errs = append(errs, errors.New("Empty auth key for "+name))
-			}
+			names = append(names, name)
 		}
 	}
+	sort.Strings(names)
+
+	workers := b.authKeyWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(names) {
+		workers = len(names)
+	}
 
+	results := make([]error, len(names))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = b.loadServiceAuthKey(ctx, names[i], keyGetter, client)
+			}
+		}()
+	}
+	for i := range names {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	errs := make([]error, 0)
+	for _, err := range results {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
 	return errs
 }
 
+// loadServiceAuthKey gets the auth key of the named service, limited to authKeyTimeout when set
+func (b *defaultConfigBuilder) loadServiceAuthKey(ctx context.Context, name string, keyGetter AuthKeyGetter, client apiclient.RetryClient) error {
+	if b.authKeyTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.authKeyTimeout)
+		defer cancel()
+	}
+
+	serviceConfig := b.config.ServiceConfigs[name]
+	key, expiresIn, err := getServiceKey(ctx, keyGetter, serviceConfig, client)
+	serviceConfig.AuthKey = key
+	serviceConfig.currentAuthKey = newAuthKeyHolder(key, expiresIn)
+
+	if err != nil {
+		return &cnErrors.ErrorLog{
+			RootCause: "Error retrieving auth key for " + name + ":",
+			Err:       err,
+		}
+	} else if key == "" {
+		return errors.New("Empty auth key for " + name)
+	}
+	return nil
+}
+
 // mergeComponentConfigsForAllServices populates mergedComponentConfigs
 // using serviceConfig and defaults
 func mergeComponentConfigsForAllServices(c *Config) error {
@@ -245,14 +591,25 @@ func mergeCompConfigs(serviceCCO *ComponentConfigs, defaultCC *ComponentConfigs,
 	return nil
 }
 
-func createHTTPClient(mc ClientConfig, mapCertPools bundleMap, rbfn RetryClientBuilderFn) apiclient.RetryClient {
+// createHTTPClient builds the retry client for the merged client config mc. When baseTransport is
+// given it is cloned and the config settings are applied on top of it, keeping any other settings
+// such as a proxy or dialer.
+func createHTTPClient(mc ClientConfig, mapCertPools bundleMap, rbfn RetryClientBuilderFn, baseTransport *http.Transport) (apiclient.RetryClient, *http.Transport) {
 	// mc mergedClient has already been merged from serviceCCO and defaultCC
 	disableCompression := false
 	if mc.DisableCompression == True {
 		disableCompression = true
 	}
 
+	transport := &http.Transport{}
+	if baseTransport != nil {
+		transport = baseTransport.Clone()
+	}
+
 	tlsConfig := &tls.Config{}
+	if transport.TLSClientConfig != nil {
+		tlsConfig = transport.TLSClientConfig
+	}
 	if mc.InsecureSkipVerify == True {
 		tlsConfig.InsecureSkipVerify = true
 	} else { // not skipping, so set cert pool
@@ -262,28 +619,33 @@ func createHTTPClient(mc ClientConfig, mapCertPools bundleMap, rbfn RetryClientB
 		}
 	}
 
+	transport.TLSClientConfig = tlsConfig
+	transport.IdleConnTimeout = time.Duration(mc.IdleConnTimeout) * time.Second
+	transport.MaxIdleConnsPerHost = mc.MaxIdleConnsPerHost
+	transport.MaxConnsPerHost = mc.MaxConnsPerHost
+	transport.DisableCompression = disableCompression
+
 	baseClient := &http.Client{
-		Timeout: time.Duration(mc.Timeout) * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig:     tlsConfig,
-			IdleConnTimeout:     time.Duration(mc.IdleConnTimeout) * time.Second,
-			MaxIdleConnsPerHost: mc.MaxIdleConnsPerHost,
-			MaxConnsPerHost:     mc.MaxConnsPerHost,
-			DisableCompression:  disableCompression,
-		},
+		Timeout:   time.Duration(mc.Timeout) * time.Second,
+		Transport: transport,
 	}
 
 	retryClient := rbfn(mc.MaxRetries, baseClient)
-	return retryClient
+	return retryClient, transport
 }
 
 // resolveCAPath resolve relative to jsonPath and if cerPath is empty
 // or resolvedPath is "." return empty string to signify no caBundlePath
 func resolveCAPath(jsonPath string, certPath string) string {
+	return resolveCAPathFromDir(path.Dir(jsonPath), certPath)
+}
+
+// resolveCAPathFromDir resolve relative to configDir and if certPath is empty
+// or resolvedPath is "." return empty string to signify no caBundlePath
+func resolveCAPathFromDir(configDir string, certPath string) string {
 	if certPath == "" {
 		return ""
 	}
-	configDir := path.Dir(jsonPath)
 	resolvedPath := compath.Resolve(configDir, certPath)
 	if resolvedPath == "." { // not a valid CABundlePath
 		return ""
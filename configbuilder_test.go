@@ -1,13 +1,16 @@
 package config
 
 import (
+	"context"
 	"fmt"
 	"github.com/CodeNamor/http/apiclient"
 	"github.com/stretchr/testify/require"
 	"io"
 	"os"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestDefaultConfigBuilder_Load(t *testing.T) {
@@ -371,6 +374,116 @@ func TestDefaultConfigBuilder_LoadServiceAuthKeys(t *testing.T) {
 	}
 }
 
+// blockingKeyGetter records how many keys are fetched at a time and blocks every fetch until release is closed
+type blockingKeyGetter struct {
+	mu      sync.Mutex
+	active  int
+	peak    int
+	release chan struct{}
+}
+
+func (g *blockingKeyGetter) GetServiceKey(service *ServiceConfig, client apiclient.RetryClient) (string, error) {
+	g.mu.Lock()
+	g.active++
+	if g.active > g.peak {
+		g.peak = g.active
+	}
+	g.mu.Unlock()
+
+	<-g.release
+
+	g.mu.Lock()
+	g.active--
+	g.mu.Unlock()
+	return "key-" + service.Name, nil
+}
+
+func newAuthKeysTestBuilder(serviceCount, workers int, timeout time.Duration) *defaultConfigBuilder {
+	services := ServicesMap{}
+	for i := 1; i <= serviceCount; i++ {
+		name := fmt.Sprintf("TEST_SERVICE_%v", i)
+		services[name] = &ServiceConfig{Name: name, AuthRequired: true}
+	}
+	return &defaultConfigBuilder{
+		config:         &Config{ServiceConfigs: services},
+		authKeyWorkers: workers,
+		authKeyTimeout: timeout,
+	}
+}
+
+func TestDefaultConfigBuilder_LoadServiceAuthKeysContext(t *testing.T) {
+	t.Run("should fetch keys concurrently up to the worker limit", func(t *testing.T) {
+		builder := newAuthKeysTestBuilder(6, 3, 0)
+		getter := &blockingKeyGetter{release: make(chan struct{})}
+
+		done := make(chan []error)
+		go func() {
+			done <- builder.LoadServiceAuthKeysContext(context.Background(), getter, nil)
+		}()
+
+		require.Eventually(t, func() bool {
+			getter.mu.Lock()
+			defer getter.mu.Unlock()
+			return getter.active == 3
+		}, time.Second, time.Millisecond)
+		close(getter.release)
+
+		require.Empty(t, <-done)
+		require.Equal(t, 3, getter.peak)
+		for name, service := range builder.config.ServiceConfigs {
+			require.Equal(t, "key-"+name, service.AuthKey)
+			require.Equal(t, "key-"+name, service.CurrentAuthKey())
+		}
+	})
+
+	t.Run("should fetch one key at a time by default", func(t *testing.T) {
+		builder := newOptions(nil).builder()
+		builder.config = newAuthKeysTestBuilder(3, 0, 0).config
+		getter := &blockingKeyGetter{release: make(chan struct{})}
+		time.AfterFunc(10*time.Millisecond, func() { close(getter.release) })
+
+		require.Empty(t, builder.LoadServiceAuthKeysContext(context.Background(), getter, nil))
+		require.Equal(t, 1, getter.peak)
+	})
+
+	t.Run("should fail every key not fetched when the context is cancelled", func(t *testing.T) {
+		builder := newAuthKeysTestBuilder(3, 2, 0)
+		getter := &blockingKeyGetter{release: make(chan struct{})}
+		defer close(getter.release)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		errs := builder.LoadServiceAuthKeysContext(ctx, getter, nil)
+		require.Len(t, errs, 3)
+		for i, err := range errs {
+			require.EqualError(t, err, fmt.Sprintf("Error retrieving auth key for TEST_SERVICE_%v: context canceled", i+1))
+		}
+	})
+
+	t.Run("should give up on each key after the timeout", func(t *testing.T) {
+		builder := newAuthKeysTestBuilder(2, 1, 10*time.Millisecond)
+		getter := &blockingKeyGetter{release: make(chan struct{})}
+		defer close(getter.release)
+
+		start := time.Now()
+		errs := builder.LoadServiceAuthKeysContext(context.Background(), getter, nil)
+		require.Less(t, time.Since(start), time.Second)
+		require.Equal(t, []string{
+			"Error retrieving auth key for TEST_SERVICE_1: context deadline exceeded",
+			"Error retrieving auth key for TEST_SERVICE_2: context deadline exceeded",
+		}, errorStrings(errs))
+	})
+}
+
+func errorStrings(errs []error) []string {
+	result := make([]string, len(errs))
+	for i, err := range errs {
+		result[i] = err.Error()
+	}
+	return result
+}
+
 func Test_mergeComponentConfigsForAllServices(t *testing.T) {
 	testcases := []struct {
 		name                 string
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newConfigDirFS(t *testing.T) fstest.MapFS {
+	caBundle, err := os.ReadFile("testdata/example_cabundle.pem")
+	require.NoError(t, err)
+	return fstest.MapFS{
+		"conf.d/00-base.json": {Data: []byte(`{
+  "Port": 8000,
+  "DefaultComponentConfigs": {"Client": {"Timeout": 10, "CABundlePath": "cabundle.pem"}},
+  "ServiceConfigs": [{"Name": "ABS", "Url": "https://abs.local"}],
+  "DatabaseConfigs": [{"Name": "DB", "Server": "db.local", "Database": "members"}]
+}`)},
+		"conf.d/10-services.json": {Data: []byte(`{
+  "ServiceConfigs": [{"Name": "ABS", "Url": "https://abs.remote"}, {"Name": "CLAIMS", "Url": "https://claims.local"}]
+}`)},
+		"conf.d/20-databases.json": {Data: []byte(`{"DatabaseConfigs": [{"Name": "DB", "Server": "db.remote"}]}`)},
+		"conf.d/README.md":         {Data: []byte(`not a config file`)},
+		"conf.d/cabundle.pem":      {Data: caBundle},
+	}
+}
+
+func TestConfigDirectory(t *testing.T) {
+	c, errs := NewFromFS(newConfigDirFS(t), "conf.d", WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+
+	require.Equal(t, 8000, c.Port)
+	require.Equal(t, "https://abs.remote", c.ServiceConfigs["ABS"].URL)
+	require.Equal(t, "https://claims.local", c.ServiceConfigs["CLAIMS"].URL)
+	require.Equal(t, "db.remote", c.DatabaseConfigs["DB"].Server)
+	require.Equal(t, "members", c.DatabaseConfigs["DB"].Database)
+	require.Equal(t, []string{"conf.d/00-base.json", "conf.d/10-services.json", "conf.d/20-databases.json"}, c.files)
+	require.Equal(t, "conf.d", c.dir, "CA bundles are resolved against the directory")
+}
+
+func TestConfigDirectory_Hash(t *testing.T) {
+	load := func(fsys fstest.MapFS) string {
+		c, errs := NewFromFS(fsys, "conf.d", WithRetryClientBuilder(httpClientBuilder))
+		require.Empty(t, errs)
+		return c.Hash
+	}
+	original := load(newConfigDirFS(t))
+	require.Equal(t, original, load(newConfigDirFS(t)), "the hash is deterministic")
+
+	changed := newConfigDirFS(t)
+	changed["conf.d/20-databases.json"] = &fstest.MapFile{Data: []byte(`{"DatabaseConfigs": [{"Name": "DB", "Server": "db.other"}]}`)}
+	require.NotEqual(t, original, load(changed), "a changed fragment changes the hash")
+
+	added := newConfigDirFS(t)
+	added["conf.d/30-options.json"] = &fstest.MapFile{Data: []byte(`{}`)}
+	require.NotEqual(t, original, load(added), "an added fragment changes the hash")
+}
+
+func TestConfigDirectory_Empty(t *testing.T) {
+	fsys := fstest.MapFS{"conf.d/README.md": {Data: []byte(`nothing here`)}}
+	c, errs := NewFromFS(fsys, "conf.d")
+	require.Nil(t, c)
+	require.Len(t, errs, 1)
+	require.EqualError(t, errs[0], "Error listing config files in directory conf.d no *.json files")
+}
+
+func TestWatcher_ConfigDirectory(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00-base.json"), []byte(`{"Port": 8000}`), 0o600))
+
+	w, errs := NewWatcher(dir, WatchSettings{Interval: -1}, WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+	defer w.Stop()
+	require.Equal(t, 8000, w.Config().Port)
+
+	replaceFile(t, filepath.Join(dir, "10-port.json"), `{"Port": 9000}`)
+	w.reloadIfChanged()
+	require.Equal(t, 9000, w.Config().Port, "an added fragment is loaded")
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "10-port.json")))
+	w.reloadIfChanged()
+	require.Equal(t, 8000, w.Config().Port, "a removed fragment is unloaded")
+}
@@ -0,0 +1,332 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// decodeProblem is a value of a config source that the decoder rejects
+type decodeProblem struct {
+	src    configSource
+	offset int
+	// path is the path to the value, or to the object holding an unknown field
+	path string
+	// unknownField is the name of an unknown field, empty for a value of the wrong type
+	unknownField string
+	suggestion   string
+	// ignored is set for the unknown fields of named list entries, which the decoder ignores
+	ignored bool
+
+	// goType is the type the decoder reports for a value of the wrong type, goPath the field the decoder reports it
+	// at: struct fields, map keys and list indexes from the value UnmarshalJSON was last called for. fieldPath holds
+	// the struct fields only, as older versions of encoding/json report them.
+	goType    reflect.Type
+	goPath    string
+	fieldPath string
+}
+
+// problemFinder checks the JSON nodes of a source against a type the way the decoder does
+type problemFinder struct {
+	src      configSource
+	problems []decodeProblem
+	// lists counts the named lists being checked, their entries are decoded by UnmarshalJSON with json.Unmarshal
+	// which ignores unknown fields
+	lists int
+}
+
+// findDecodeProblems checks each source against the Config type as the decoder does, sources that cannot be
+// converted or parsed are skipped
+func findDecodeProblems(sources []configSource) []decodeProblem {
+	var problems []decodeProblem
+	for _, src := range sources {
+		jsonBytes, err := toJSON(src.data, src.format)
+		if err != nil {
+			continue
+		}
+		root, err := parseJSONNodes(jsonBytes)
+		if err != nil {
+			continue
+		}
+
+		f := &problemFinder{src: src}
+		f.check(root, reflect.TypeOf(Config{}), "", "", "")
+		problems = append(problems, f.problems...)
+	}
+	return problems
+}
+
+// ignoredFields returns a message for each unknown field of the ServiceConfigs, DatabaseConfigs and EndPoints
+// entries of sources, with its location and the known field with the closest name. The decoder ignores them, so a
+// misspelled field would otherwise go unnoticed.
+func ignoredFields(sources []configSource) []string {
+	var messages []string
+	for _, problem := range findDecodeProblems(sources) {
+		if problem.ignored {
+			err := problem.describe(fmt.Errorf("unknown field %q", problem.unknownField))
+			messages = append(messages, "Ignoring "+err.Error())
+		}
+	}
+	return messages
+}
+
+// locateDecodeError adds to err, returned by the decoder, where the rejected value is: the source file with the line
+// and column, the path to the value such as ServiceConfigs[2].ComponentConfigOverrides.Client and, for an unknown
+// field, the known field with the closest name. The value is found by checking the sources against the Config type
+// as the decoder does, err is returned as is when no value or more than one value matches it.
+func locateDecodeError(sources []configSource, err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		if len(sources) == 1 {
+			return locateSyntaxError(sources[0], err)
+		}
+		return err
+	}
+
+	unknownField, isUnknownField := unknownFieldName(err)
+	var typeErr *json.UnmarshalTypeError
+	isTypeErr := errors.As(err, &typeErr)
+	if !isUnknownField && !isTypeErr {
+		return err
+	}
+
+	var matches []decodeProblem
+	for _, problem := range findDecodeProblems(sources) {
+		if isUnknownField && !problem.ignored && problem.unknownField == unknownField {
+			matches = append(matches, problem)
+		}
+		if isTypeErr && problem.unknownField == "" && problem.goType == typeErr.Type &&
+			(typeErr.Field == problem.goPath || typeErr.Field == problem.fieldPath) {
+			matches = append(matches, problem)
+		}
+	}
+	if len(matches) != 1 {
+		return err
+	}
+	return matches[0].describe(err)
+}
+
+// locateSyntaxError adds the location of a syntax error in src to err
+func locateSyntaxError(src configSource, err error) error {
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return err
+	}
+	// Offset counts the bytes read, the offending one included
+	offset := int(syntaxErr.Offset) - 1
+	if offset < 0 {
+		offset = 0
+	}
+	return fmt.Errorf("%w at %v", err, src.location(offset))
+}
+
+// describe adds the location of the problem to err
+func (problem decodeProblem) describe(err error) error {
+	location := problem.src.location(problem.offset)
+	if problem.path != "" {
+		location += " " + problem.path
+	}
+	if problem.suggestion != "" {
+		return fmt.Errorf("%w at %v, did you mean %q?", err, location, problem.suggestion)
+	}
+	return fmt.Errorf("%w at %v", err, location)
+}
+
+// unknownFieldName returns the field named by an unknown field error of the decoder
+func unknownFieldName(err error) (string, bool) {
+	const prefix = "json: unknown field "
+	message := err.Error()
+	if !strings.HasPrefix(message, prefix) {
+		return "", false
+	}
+	name, unquoteErr := strconv.Unquote(strings.TrimPrefix(message, prefix))
+	return name, unquoteErr == nil
+}
+
+// check records the problems of node, decoded into a value of type t found at path in the Config. goPath and
+// fieldPath are the paths the decoder reports for the value, see decodeProblem.
+func (f *problemFinder) check(node *jsonNode, t reflect.Type, path string, goPath string, fieldPath string) {
+	if node.value == nil { // null leaves any value as is
+		return
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	mismatchOf := func(goType reflect.Type, goPath string, fieldPath string) {
+		f.problems = append(f.problems, decodeProblem{
+			src:       f.src,
+			offset:    node.offset,
+			path:      path,
+			goType:    goType,
+			goPath:    goPath,
+			fieldPath: fieldPath,
+		})
+	}
+	mismatch := func() {
+		mismatchOf(t, goPath, fieldPath)
+	}
+
+	// named lists and flags are decoded by their UnmarshalJSON, which reports the values it rejects from itself
+	if namedListTypes[t] {
+		items, ok := node.value.([]*jsonNode)
+		if !ok {
+			mismatchOf(reflect.SliceOf(t.Elem().Elem()), "", "")
+			return
+		}
+		f.lists++
+		for i, item := range items {
+			f.check(item, t.Elem(), path+"["+strconv.Itoa(i)+"]", strconv.Itoa(i), "")
+		}
+		f.lists--
+		return
+	}
+	if t == reflect.TypeOf(configFlag(0)) {
+		if _, isBool := node.value.(bool); !isBool && jsonType(node) != "integer" {
+			mismatchOf(reflect.TypeOf(0), "", "")
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		members, ok := node.value.([]jsonMember)
+		if !ok {
+			mismatch()
+			return
+		}
+		for _, member := range members {
+			field, found := structField(t, member.key)
+			if !found {
+				f.problems = append(f.problems, decodeProblem{
+					src:          f.src,
+					offset:       member.keyOffset,
+					path:         path,
+					unknownField: member.key,
+					suggestion:   closestName(member.key, structFieldNames(t)),
+					ignored:      f.lists != 0,
+				})
+				continue
+			}
+			f.check(member.value, field.Type, joinPath(path, fieldJSONName(field)), joinPath(goPath, field.Name), joinPath(fieldPath, field.Name))
+		}
+	case reflect.String:
+		if _, ok := node.value.(string); !ok {
+			mismatch()
+		}
+	case reflect.Bool:
+		if _, ok := node.value.(bool); !ok {
+			mismatch()
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if jsonType(node) != "integer" {
+			mismatch()
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, ok := node.value.(json.Number); !ok {
+			mismatch()
+		}
+	case reflect.Slice:
+		items, ok := node.value.([]*jsonNode)
+		if !ok {
+			mismatch()
+			return
+		}
+		for i, item := range items {
+			f.check(item, t.Elem(), path+"["+strconv.Itoa(i)+"]", joinPath(goPath, strconv.Itoa(i)), fieldPath)
+		}
+	case reflect.Map:
+		members, ok := node.value.([]jsonMember)
+		if !ok {
+			mismatch()
+			return
+		}
+		for _, member := range members {
+			f.check(member.value, t.Elem(), joinPath(path, member.key), joinPath(goPath, member.key), fieldPath)
+		}
+	}
+}
+
+// structField returns the field of t the decoder fills from the key, preferring an exact match to a case insensitive one
+func structField(t reflect.Type, key string) (reflect.StructField, bool) {
+	var folded *reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || fieldJSONName(field) == "-" {
+			continue
+		}
+		name := fieldJSONName(field)
+		if name == key {
+			return field, true
+		}
+		if folded == nil && strings.EqualFold(name, key) {
+			folded = &field
+		}
+	}
+	if folded != nil {
+		return *folded, true
+	}
+	return reflect.StructField{}, false
+}
+
+// structFieldNames returns the names of the fields of t read from config files
+func structFieldNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath == "" && fieldJSONName(field) != "-" {
+			names = append(names, fieldJSONName(field))
+		}
+	}
+	return names
+}
+
+// fieldJSONName returns the name of field in config files
+func fieldJSONName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// closestName returns the name closest to key, ignoring case, when it is close enough to be a likely misspelling
+func closestName(key string, names []string) string {
+	closest, closestDistance := "", len(key)/3+1
+	for _, name := range names {
+		if distance := editDistance(strings.ToLower(key), strings.ToLower(name)); distance < closestDistance {
+			closest, closestDistance = name, distance
+		}
+	}
+	return closest
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
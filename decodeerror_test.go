@@ -0,0 +1,178 @@
+package config
+
+import (
+	"testing"
+	"testing/fstest"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocateDecodeError(t *testing.T) {
+	testcases := []struct {
+		name          string
+		files         map[string]string
+		configPath    string
+		opts          []Option
+		expectedError string
+	}{
+		{
+			name:       "misspelled field",
+			configPath: "config.json",
+			files: map[string]string{
+				"config.json": `{
+  "Port": 8000,
+  "DefaultComponentConfigs": {
+    "Client": {"Timout": 10}
+  }
+}`,
+			},
+			expectedError: `Error decoding config data json: unknown field "Timout" at config.json:4:16 DefaultComponentConfigs.Client, did you mean "Timeout"?`,
+		},
+		{
+			name:       "value of the wrong type in a list",
+			configPath: "config.json",
+			files: map[string]string{
+				"config.json": `{
+  "Port": 8000,
+  "ServiceConfigs": [
+    {"Name": "ABS", "Url": "https://abs.local", "ComponentConfigOverrides": {"Client": {"Timeout": 10}}},
+    {"Name": "CLAIMS", "Url": "https://claims.local"},
+    {"Name": "MEMBERS", "Url": "https://members.local",
+     "ComponentConfigOverrides": {"Client": {"Timeout": "10"}}}
+  ]
+}`,
+			},
+			expectedError: "Error decoding config data json: cannot unmarshal string into Go struct field .2.ComponentConfigOverrides.Client.Timeout of type int at config.json:7:57 ServiceConfigs[2].ComponentConfigOverrides.Client.Timeout",
+		},
+		{
+			name:       "unknown field without a close name",
+			configPath: "config.json",
+			files: map[string]string{
+				"config.json": `{"Port": 8000, "OopsBadField": 123}`,
+			},
+			expectedError: `Error decoding config data json: unknown field "OopsBadField" at config.json:1:16`,
+		},
+		{
+			name:       "value of the wrong type",
+			configPath: "config.json",
+			files: map[string]string{
+				"config.json": `{
+  "Port": 8000,
+  "DefaultComponentConfigs": {"Client": {"Timeout": "10"}}
+}`,
+			},
+			expectedError: "Error decoding config data json: cannot unmarshal string into Go struct field .DefaultComponentConfigs.Client.Timeout of type int at config.json:3:53 DefaultComponentConfigs.Client.Timeout",
+		},
+		{
+			name:       "flag of the wrong type",
+			configPath: "config.json",
+			files: map[string]string{
+				"config.json": `{"Port": 8000, "ServiceConfigs": [{"Name": "ABS", "ComponentConfigOverrides": {"Client": {"DisableCompression": "yes"}}}]}`,
+			},
+			expectedError: "Error decoding config data json: cannot unmarshal string into Go value of type int at config.json:1:113 ServiceConfigs[0].ComponentConfigOverrides.Client.DisableCompression",
+		},
+		{
+			name:       "misspelled field in an overlay",
+			configPath: "config.json",
+			opts:       []Option{WithOverlays("overlay.json")},
+			files: map[string]string{
+				"config.json":  `{"Port": 8000, "Logging": {"Level": "info"}}`,
+				"overlay.json": "{\n  \"Logging\": {\"Levl\": \"debug\"}\n}",
+			},
+			expectedError: `Error decoding config data json: unknown field "Levl" at overlay.json:2:15 Logging, did you mean "Level"?`,
+		},
+		{
+			name:       "other formats are located by path",
+			configPath: "config.yaml",
+			files: map[string]string{
+				"config.yaml": "Port: 8000\nLogging:\n  Levl: info\n",
+			},
+			expectedError: `Error decoding config data json: unknown field "Levl" at config.yaml Logging, did you mean "Level"?`,
+		},
+		{
+			name:       "unknown field found in several places",
+			configPath: "config.json",
+			opts:       []Option{WithOverlays("overlay.json")},
+			files: map[string]string{
+				"config.json":  `{"Port": 8000, "Logging": {"Levl": "info"}}`,
+				"overlay.json": `{"DefaultComponentConfigs": {"ServiceLogging": {"Levl": "debug"}}}`,
+			},
+			expectedError: `Error decoding config data json: unknown field "Levl"`,
+		},
+		{
+			name:       "flag of the wrong type in several places",
+			configPath: "config.json",
+			files: map[string]string{
+				"config.json": `{"Port": 8000, "DefaultComponentConfigs": {"Client": {"DisableCompression": "yes", "InsecureSkipVerify": "no"}}}`,
+			},
+			expectedError: "Error decoding config data json: cannot unmarshal string into Go value of type int",
+		},
+		{
+			name:       "syntax error",
+			configPath: "config.json",
+			files: map[string]string{
+				"config.json": "{\n  \"Port\": 8000\n  \"Env\": \"Dev\"\n}",
+			},
+			expectedError: `Error decoding config data invalid character '"' after object key:value pair at config.json:3:3`,
+		},
+		{
+			name:       "syntax error in an overlay",
+			configPath: "config.json",
+			opts:       []Option{WithOverlays("overlay.json")},
+			files: map[string]string{
+				"config.json":  `{"Port": 8000}`,
+				"overlay.json": `{"Env": Dev}`,
+			},
+			expectedError: `Error converting json config data overlay.json invalid character 'D' looking for beginning of value at overlay.json:1:9`,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for name, data := range tc.files {
+				fsys[name] = &fstest.MapFile{Data: []byte(data)}
+			}
+
+			c, errs := NewFromFS(fsys, tc.configPath, append([]Option{WithRetryClientBuilder(httpClientBuilder)}, tc.opts...)...)
+			require.Nil(t, c)
+			require.Len(t, errs, 1)
+			require.EqualError(t, errs[0], tc.expectedError)
+		})
+	}
+}
+
+func TestLocateDecodeError_ListEntriesIgnoreUnknownFields(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	data := `{
+  "Port": 8000,
+  "ServiceConfigs": [
+    {"Name": "ABS", "Url": "https://abs.local", "Retries": 3,
+     "ComponentConfigOverrides": {"Client": {"Tmeout": 10}}}
+  ]
+}`
+	c, errs := NewFromBytes([]byte(data), "", WithRetryClientBuilder(httpClientBuilder), WithLogger(logger))
+	require.Empty(t, errs)
+	require.Equal(t, "https://abs.local", c.ServiceConfigs["ABS"].URL)
+
+	warnings := []string{}
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == log.WarnLevel {
+			warnings = append(warnings, entry.Message)
+		}
+	}
+	require.Equal(t, []string{
+		`Ignoring unknown field "Retries" at config data:4:49 ServiceConfigs[0]`,
+		`Ignoring unknown field "Tmeout" at config data:5:46 ServiceConfigs[0].ComponentConfigOverrides.Client, did you mean "Timeout"?`,
+	}, warnings)
+}
+
+func TestClosestName(t *testing.T) {
+	names := []string{"Timeout", "IdleConnTimeout", "MaxRetries", "CABundlePath"}
+	require.Equal(t, "Timeout", closestName("Timout", names))
+	require.Equal(t, "Timeout", closestName("timeout", names))
+	require.Equal(t, "MaxRetries", closestName("MaxRetrys", names))
+	require.Equal(t, "", closestName("Retries", names))
+	require.Equal(t, "", closestName("Url", names))
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// mergedClientField is the prefix of the FieldChanges describing the merged client config of a service
+const mergedClientField = "MergedComponentConfigs.Client."
+
+// FieldChange is a value that differs between two configs. Field is the path to the value with
+// segments separated by dots, e.g. DefaultComponentConfigs.Client.Timeout.
+type FieldChange struct {
+	Field string
+	Old   interface{}
+	New   interface{}
+}
+
+// Diff is a structured comparison of two configs. Names and keys are sorted.
+type Diff struct {
+	// Fields are the changes to the config outside of ServiceConfigs, DatabaseConfigs and Options
+	Fields []FieldChange
+
+	ServicesAdded   []string
+	ServicesRemoved []string
+	// ServicesChanged holds the field changes of each service found in both configs, changes to the
+	// client config merged with the defaults are listed as MergedComponentConfigs.Client.<field>
+	ServicesChanged map[string][]FieldChange
+
+	DatabasesAdded   []string
+	DatabasesRemoved []string
+	DatabasesChanged map[string][]FieldChange
+
+	OptionsAdded   []string
+	OptionsRemoved []string
+	OptionsChanged []string
+}
+
+// DiffConfigs compares old and new. Http clients are not compared, and neither is the Hash since it changes with any
+// change to the config files. A nil config is compared as an empty one.
+func DiffConfigs(old, new *Config) Diff {
+	if old == nil {
+		old = &Config{}
+	}
+	if new == nil {
+		new = &Config{}
+	}
+	diff := Diff{
+		ServicesChanged:  map[string][]FieldChange{},
+		DatabasesChanged: map[string][]FieldChange{},
+	}
+	skip := map[string]bool{"ServiceConfigs": true, "DatabaseConfigs": true, "Options": true, "Hash": true}
+	diffValues("", reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem(), skip, &diff.Fields)
+
+	diff.ServicesAdded, diff.ServicesRemoved = addedRemoved(reflect.ValueOf(old.ServiceConfigs), reflect.ValueOf(new.ServiceConfigs))
+	for name, oldService := range old.ServiceConfigs {
+		newService, ok := new.ServiceConfigs[name]
+		if !ok {
+			continue
+		}
+		var changes []FieldChange
+		diffValues("", reflect.ValueOf(oldService).Elem(), reflect.ValueOf(newService).Elem(), nil, &changes)
+		diffValues(strings.TrimSuffix(mergedClientField, "."), reflect.ValueOf(oldService.MergedComponentConfigs().Client),
+			reflect.ValueOf(newService.MergedComponentConfigs().Client), nil, &changes)
+		if len(changes) != 0 {
+			diff.ServicesChanged[name] = changes
+		}
+	}
+
+	diff.DatabasesAdded, diff.DatabasesRemoved = addedRemoved(reflect.ValueOf(old.DatabaseConfigs), reflect.ValueOf(new.DatabaseConfigs))
+	for name, oldDatabase := range old.DatabaseConfigs {
+		newDatabase, ok := new.DatabaseConfigs[name]
+		if !ok {
+			continue
+		}
+		var changes []FieldChange
+		diffValues("", reflect.ValueOf(oldDatabase).Elem(), reflect.ValueOf(newDatabase).Elem(), nil, &changes)
+		if len(changes) != 0 {
+			diff.DatabasesChanged[name] = changes
+		}
+	}
+
+	diff.OptionsAdded, diff.OptionsRemoved = addedRemoved(reflect.ValueOf(old.Options), reflect.ValueOf(new.Options))
+	for key, oldValue := range old.Options {
+		if newValue, ok := new.Options[key]; ok && !reflect.DeepEqual(oldValue, newValue) {
+			diff.OptionsChanged = append(diff.OptionsChanged, key)
+		}
+	}
+	sort.Strings(diff.OptionsChanged)
+
+	return diff
+}
+
+// IsEmpty reports whether the compared configs are the same
+func (d Diff) IsEmpty() bool {
+	return len(d.Fields) == 0 &&
+		len(d.ServicesAdded) == 0 && len(d.ServicesRemoved) == 0 && len(d.ServicesChanged) == 0 &&
+		len(d.DatabasesAdded) == 0 && len(d.DatabasesRemoved) == 0 && len(d.DatabasesChanged) == 0 &&
+		len(d.OptionsAdded) == 0 && len(d.OptionsRemoved) == 0 && len(d.OptionsChanged) == 0
+}
+
+// ClientChanges returns the changes to the merged client config of the named service, with Field set to the
+// ClientConfig field name. A service whose http client must be rebuilt has client changes, or was added.
+func (d Diff) ClientChanges(service string) []FieldChange {
+	var changes []FieldChange
+	for _, change := range d.ServicesChanged[service] {
+		if strings.HasPrefix(change.Field, mergedClientField) {
+			change.Field = strings.TrimPrefix(change.Field, mergedClientField)
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// diffValues appends a FieldChange for every exported, decodable field that differs between the structs old and new,
+// walking nested structs. Fields named in skip are ignored at the top level.
+func diffValues(path string, old, new reflect.Value, skip map[string]bool, changes *[]FieldChange) {
+	if old.Kind() != reflect.Struct {
+		if !reflect.DeepEqual(old.Interface(), new.Interface()) {
+			*changes = append(*changes, FieldChange{Field: path, Old: old.Interface(), New: new.Interface()})
+		}
+		return
+	}
+
+	t := old.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || field.Tag.Get("json") == "-" || skip[field.Name] {
+			continue
+		}
+		fieldPath := field.Name
+		if path != "" {
+			fieldPath = path + "." + field.Name
+		}
+		diffValues(fieldPath, old.Field(i), new.Field(i), nil, changes)
+	}
+}
+
+// addedRemoved returns the sorted keys of the string keyed map new missing from old, and of old missing from new
+func addedRemoved(old, new reflect.Value) (added, removed []string) {
+	for _, key := range new.MapKeys() {
+		if !old.MapIndex(key).IsValid() {
+			added = append(added, key.String())
+		}
+	}
+	for _, key := range old.MapKeys() {
+		if !new.MapIndex(key).IsValid() {
+			removed = append(removed, key.String())
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const diffTestConfig = `{
+  "Port": 8000,
+  "DefaultComponentConfigs": {"Client": {"Timeout": 10}},
+  "ServiceConfigs": [
+    {"Name": "ABS", "Url": "https://abs.local"},
+    {"Name": "CLAIMS", "Url": "https://claims.local"}
+  ],
+  "DatabaseConfigs": [{"Name": "DB", "Server": "db.local"}],
+  "Options": {"TRMemberInquiry": true, "Region": "east"}
+}`
+
+func newDiffTestConfig(t *testing.T, data string) *Config {
+	c, errs := NewFromBytes([]byte(data), "", WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+	return c
+}
+
+func TestDiffConfigs(t *testing.T) {
+	testcases := []struct {
+		name     string
+		new      string
+		expected Diff
+	}{
+		{
+			name: "same config",
+			new:  diffTestConfig,
+			expected: Diff{
+				ServicesChanged:  map[string][]FieldChange{},
+				DatabasesChanged: map[string][]FieldChange{},
+			},
+		},
+		{
+			name: "default client change is seen by every service",
+			new: `{
+  "Port": 9000,
+  "DefaultComponentConfigs": {"Client": {"Timeout": 20}},
+  "ServiceConfigs": [
+    {"Name": "ABS", "Url": "https://abs.local"},
+    {"Name": "CLAIMS", "Url": "https://claims.local", "ComponentConfigOverrides": {"Client": {"Timeout": 10}}}
+  ],
+  "DatabaseConfigs": [{"Name": "DB", "Server": "db.local"}],
+  "Options": {"TRMemberInquiry": true, "Region": "east"}
+}`,
+			expected: Diff{
+				Fields: []FieldChange{
+					{Field: "Port", Old: 8000, New: 9000},
+					{Field: "DefaultComponentConfigs.Client.Timeout", Old: 10, New: 20},
+				},
+				ServicesChanged: map[string][]FieldChange{
+					"ABS":    {{Field: "MergedComponentConfigs.Client.Timeout", Old: 10, New: 20}},
+					"CLAIMS": {{Field: "ComponentConfigOverrides.Client.Timeout", Old: 0, New: 10}},
+				},
+				DatabasesChanged: map[string][]FieldChange{},
+			},
+		},
+		{
+			name: "services, databases and options added, removed and changed",
+			new: `{
+  "Port": 8000,
+  "DefaultComponentConfigs": {"Client": {"Timeout": 10}},
+  "ServiceConfigs": [
+    {"Name": "ABS", "Url": "https://abs.remote", "ComponentConfigOverrides": {"Client": {"InsecureSkipVerify": true}}},
+    {"Name": "MEMBERS", "Url": "https://members.local"}
+  ],
+  "DatabaseConfigs": [{"Name": "DB", "Server": "db.remote"}, {"Name": "AUDIT"}],
+  "Options": {"TRMemberInquiry": false, "Tenant": "acme"}
+}`,
+			expected: Diff{
+				ServicesAdded:   []string{"MEMBERS"},
+				ServicesRemoved: []string{"CLAIMS"},
+				ServicesChanged: map[string][]FieldChange{
+					"ABS": {
+						{Field: "URL", Old: "https://abs.local", New: "https://abs.remote"},
+						{Field: "ComponentConfigOverrides.Client.InsecureSkipVerify", Old: UnSet, New: True},
+						{Field: "MergedComponentConfigs.Client.InsecureSkipVerify", Old: UnSet, New: True},
+					},
+				},
+				DatabasesAdded: []string{"AUDIT"},
+				DatabasesChanged: map[string][]FieldChange{
+					"DB": {{Field: "Server", Old: "db.local", New: "db.remote"}},
+				},
+				OptionsAdded:   []string{"Tenant"},
+				OptionsRemoved: []string{"Region"},
+				OptionsChanged: []string{"TRMemberInquiry"},
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			diff := DiffConfigs(newDiffTestConfig(t, diffTestConfig), newDiffTestConfig(t, tc.new))
+			require.Equal(t, tc.expected, diff)
+			require.Equal(t, tc.name == "same config", diff.IsEmpty())
+		})
+	}
+}
+
+func TestDiff_ClientChanges(t *testing.T) {
+	diff := Diff{
+		ServicesChanged: map[string][]FieldChange{
+			"ABS": {
+				{Field: "URL", Old: "a", New: "b"},
+				{Field: "ComponentConfigOverrides.Client.Timeout", Old: 0, New: 5},
+				{Field: "MergedComponentConfigs.Client.Timeout", Old: 10, New: 5},
+			},
+		},
+	}
+	require.Equal(t, []FieldChange{{Field: "Timeout", Old: 10, New: 5}}, diff.ClientChanges("ABS"))
+	require.Empty(t, diff.ClientChanges("CLAIMS"))
+}
+
+func TestDiffConfigs_Nil(t *testing.T) {
+	c, errs := NewFromBytes([]byte(`{"Port": 8000, "ServiceConfigs": [{"Name": "ABS"}], "Options": {"Region": "east"}}`), "",
+		WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+
+	added := DiffConfigs(nil, c)
+	require.Equal(t, []FieldChange{{Field: "Port", Old: 0, New: 8000}}, added.Fields)
+	require.Equal(t, []string{"ABS"}, added.ServicesAdded)
+	require.Equal(t, []string{"Region"}, added.OptionsAdded)
+
+	removed := DiffConfigs(c, nil)
+	require.Equal(t, []string{"ABS"}, removed.ServicesRemoved)
+	require.Equal(t, []string{"Region"}, removed.OptionsRemoved)
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// envPathSeparator separates the segments of the path to a config value in an environment variable name
+const envPathSeparator = "__"
+
+// applyEnvOverrides sets config values from the environ entries (KEY=value as returned by os.Environ) whose names
+// start with prefix followed by an underscore. The rest of the name is the path to the value with segments separated
+// by a double underscore, fields are matched case insensitively and map entries are addressed by their Name, e.g.
+//
+//	APP_PORT=9000
+//	APP_LOGGING__LEVEL=info
+//	APP_SERVICECONFIGS__ABS__URL=https://abs.local
+//	APP_DEFAULTCOMPONENTCONFIGS__CLIENT__INSECURESKIPVERIFY=true
+//	APP_OPTIONS__TRMEMBERINQUIRY=false
+//
+// Values are decoded as they would be from a JSON config file, so configFlag values accept true, false, 0, 1 and 2.
+// Variables whose path does not lead to a config value, such as APP_VERSION, are left out and returned as ignored
+// so another setting sharing the prefix does not fail the load. An empty prefix disables environment overrides.
+func applyEnvOverrides(c *Config, prefix string, environ []string) (ignored []string, err error) {
+	if prefix == "" {
+		return nil, nil
+	}
+	prefix = strings.ToUpper(strings.TrimSuffix(prefix, "_") + "_")
+
+	variables := append([]string(nil), environ...)
+	sort.Strings(variables)
+
+	var errs []error
+	for _, variable := range variables {
+		name, value, found := strings.Cut(variable, "=")
+		if !found || !strings.HasPrefix(strings.ToUpper(name), prefix) {
+			continue
+		}
+
+		segments := strings.Split(name[len(prefix):], envPathSeparator)
+		err := setPath(reflect.ValueOf(c).Elem(), segments, value)
+		var unknown *unknownPathError
+		switch {
+		case errors.As(err, &unknown):
+			ignored = append(ignored, fmt.Sprintf("Ignoring environment variable %v: %v", name, err))
+		case err != nil:
+			errs = append(errs, fmt.Errorf("environment variable %v: %w", name, err))
+		}
+	}
+	return ignored, errors.Join(errs...)
+}
+
+// unknownPathError reports a path segment that matches no field or entry of the config
+type unknownPathError struct {
+	problem string
+}
+
+func (e *unknownPathError) Error() string {
+	return e.problem
+}
+
+// unknownPath creates the unknownPathError described by the format and its args
+func unknownPath(format string, args ...interface{}) error {
+	return &unknownPathError{problem: fmt.Sprintf(format, args...)}
+}
+
+// setPath walks v following segments and sets the value found at the end of the path
+func setPath(v reflect.Value, segments []string, value string) error {
+	if segments[0] == "" {
+		return unknownPath("empty field name")
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return fmt.Errorf("no value to set %v in", segments[0])
+		}
+		return setPath(v.Elem(), segments, value)
+	case reflect.Struct:
+		field, ok := fieldByName(v, segments[0])
+		if !ok {
+			return unknownPath("unknown field %v", segments[0])
+		}
+		if len(segments) == 1 {
+			return setValue(field, value)
+		}
+		return setPath(field, segments[1:], value)
+	case reflect.Map:
+		return setMapPath(v, segments, value)
+	default:
+		return unknownPath("cannot set %v in a %v value", segments[0], v.Kind())
+	}
+}
+
+// setMapPath sets a value inside the map v. Entries of ServicesMap, DatabasesMap and EndpointMap must already exist,
+// while entries of interface maps such as Options are created when missing.
+func setMapPath(v reflect.Value, segments []string, value string) error {
+	key, found := mapKey(v, segments[0])
+
+	if v.Type().Elem().Kind() != reflect.Interface {
+		if !found {
+			return unknownPath("no entry named %v", segments[0])
+		}
+		if len(segments) == 1 {
+			return fmt.Errorf("cannot replace the whole %v entry", segments[0])
+		}
+		return setPath(v.MapIndex(key), segments[1:], value)
+	}
+
+	if v.IsNil() {
+		if !v.CanSet() {
+			return fmt.Errorf("cannot set %v", segments[0])
+		}
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+	if !found {
+		key = reflect.ValueOf(segments[0])
+	}
+
+	if len(segments) == 1 {
+		v.SetMapIndex(key, reflect.ValueOf(parseOptionValue(value)))
+		return nil
+	}
+
+	var nested map[string]interface{}
+	if found {
+		var ok bool
+		if nested, ok = v.MapIndex(key).Interface().(map[string]interface{}); !ok {
+			return fmt.Errorf("%v is not an object", segments[0])
+		}
+	} else {
+		nested = map[string]interface{}{}
+		v.SetMapIndex(key, reflect.ValueOf(nested))
+	}
+	return setPath(reflect.ValueOf(nested), segments[1:], value)
+}
+
+// setValue decodes value into the field, strings are used as is and everything else is decoded as JSON
+func setValue(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+		return nil
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Ptr, reflect.Interface:
+		return fmt.Errorf("cannot set a %v value from the environment", field.Kind())
+	}
+
+	if lower := strings.ToLower(value); lower == "true" || lower == "false" {
+		value = lower
+	}
+	if err := json.Unmarshal([]byte(value), field.Addr().Interface()); err != nil {
+		return fmt.Errorf("invalid value %q: %w", value, err)
+	}
+	return nil
+}
+
+// parseOptionValue decodes value as JSON so Options values have the same types they would have when read from a
+// config file, anything that is not valid JSON is kept as a string
+func parseOptionValue(value string) interface{} {
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return value
+	}
+	return parsed
+}
+
+// fieldByName finds the exported, decodable field of the struct v whose name or json name matches name case
+// insensitively
+func fieldByName(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+		jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if jsonName == "-" {
+			continue
+		}
+		if strings.EqualFold(field.Name, name) || (jsonName != "" && strings.EqualFold(jsonName, name)) {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// mapKey finds the key of the string keyed map v matching name, preferring an exact match
+func mapKey(v reflect.Value, name string) (reflect.Value, bool) {
+	exact := reflect.ValueOf(name).Convert(v.Type().Key())
+	if v.MapIndex(exact).IsValid() {
+		return exact, true
+	}
+	for _, key := range v.MapKeys() {
+		if strings.EqualFold(key.String(), name) {
+			return key, true
+		}
+	}
+	return reflect.Value{}, false
+}
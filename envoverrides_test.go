@@ -0,0 +1,202 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newEnvTestConfig() *Config {
+	return &Config{
+		Env:  "UnitTest",
+		Port: 8000,
+		DefaultComponentConfigs: ComponentConfigs{
+			Client: ClientConfig{
+				Timeout: 10,
+			},
+		},
+		ServiceConfigs: ServicesMap{
+			"ABS": &ServiceConfig{
+				Name: "ABS",
+				URL:  "https://some.url.com",
+				EndPoints: EndpointMap{
+					"ClaimStatus": &EndpointConfig{
+						Name: "ClaimStatus",
+						Path: "/mvClaimStatuses?",
+					},
+				},
+			},
+		},
+		DatabaseConfigs: DatabasesMap{
+			"MDBAuth": &DatabaseConfig{
+				Name:   "MDBAuth",
+				Server: "MyServer:1433",
+			},
+		},
+		Options: map[string]interface{}{
+			"DummyString": "a dumb string",
+		},
+	}
+}
+
+func Test_applyEnvOverrides(t *testing.T) {
+	testcases := []struct {
+		name            string
+		prefix          string
+		environ         []string
+		check           func(t *testing.T, c *Config)
+		expectedIgnored []string
+		expectedError   string
+	}{
+		{
+			name:    "empty prefix disables overrides",
+			prefix:  "",
+			environ: []string{"_PORT=9000"},
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, 8000, c.Port)
+			},
+		},
+		{
+			name:    "top level and nested fields",
+			prefix:  "APP",
+			environ: []string{"APP_PORT=9000", "APP_LOGGING__LEVEL=info", "APP_ENV=Prod", "OTHER_PORT=1"},
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, 9000, c.Port)
+				require.Equal(t, "info", c.Logging.Level)
+				require.Equal(t, "Prod", c.Env)
+			},
+		},
+		{
+			name:    "prefix with trailing underscore",
+			prefix:  "APP_",
+			environ: []string{"APP_PORT=9000"},
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, 9000, c.Port)
+			},
+		},
+		{
+			name:   "default client settings and config flags",
+			prefix: "APP",
+			environ: []string{
+				"APP_DEFAULTCOMPONENTCONFIGS__CLIENT__TIMEOUT=30",
+				"APP_DEFAULTCOMPONENTCONFIGS__CLIENT__INSECURESKIPVERIFY=TRUE",
+				"APP_DEFAULTCOMPONENTCONFIGS__CLIENT__DISABLECOMPRESSION=1",
+			},
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, 30, c.DefaultComponentConfigs.Client.Timeout)
+				require.Equal(t, True, c.DefaultComponentConfigs.Client.InsecureSkipVerify)
+				require.Equal(t, False, c.DefaultComponentConfigs.Client.DisableCompression)
+			},
+		},
+		{
+			name:   "map entries by name",
+			prefix: "APP",
+			environ: []string{
+				"APP_SERVICECONFIGS__ABS__URL=https://abs.local",
+				"APP_SERVICECONFIGS__ABS__ENDPOINTS__CLAIMSTATUS__PATH=/claims",
+				"APP_SERVICECONFIGS__ABS__COMPONENTCONFIGOVERRIDES__CLIENT__TIMEOUT=45",
+				"APP_DATABASECONFIGS__MDBAUTH__SERVER=localhost:1433",
+			},
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, "https://abs.local", c.ServiceConfigs["ABS"].URL)
+				require.Equal(t, "/claims", c.ServiceConfigs["ABS"].EndPoints["ClaimStatus"].Path)
+				require.Equal(t, 45, c.ServiceConfigs["ABS"].ComponentConfigOverrides.Client.Timeout)
+				require.Equal(t, "localhost:1433", c.DatabaseConfigs["MDBAuth"].Server)
+			},
+		},
+		{
+			name:   "options values",
+			prefix: "APP",
+			environ: []string{
+				"APP_OPTIONS__DUMMYSTRING=changed",
+				"APP_OPTIONS__TRMEMBERINQUIRY=true",
+				"APP_OPTIONS__LIMITS__MAX=8",
+			},
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, "changed", c.Options["DummyString"])
+				require.Equal(t, true, c.Options["TRMEMBERINQUIRY"])
+				require.Equal(t, map[string]interface{}{"MAX": float64(8)}, c.Options["LIMITS"])
+			},
+		},
+		{
+			name:            "unknown field",
+			prefix:          "APP",
+			environ:         []string{"APP_LOGGING__LEVL=info", "APP_VERSION=1.2.3", "APP_PORT=9000"},
+			expectedIgnored: []string{"Ignoring environment variable APP_LOGGING__LEVL: unknown field LEVL", "Ignoring environment variable APP_VERSION: unknown field VERSION"},
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, 9000, c.Port)
+				require.Equal(t, newEnvTestConfig().Logging, c.Logging)
+			},
+		},
+		{
+			name:            "unknown service",
+			prefix:          "APP",
+			environ:         []string{"APP_SERVICECONFIGS__NOPE__URL=x"},
+			expectedIgnored: []string{"Ignoring environment variable APP_SERVICECONFIGS__NOPE__URL: no entry named NOPE"},
+			check: func(t *testing.T, c *Config) {
+				require.NotContains(t, c.ServiceConfigs, "NOPE")
+			},
+		},
+		{
+			name:            "path below a value",
+			prefix:          "APP",
+			environ:         []string{"APP_PORT__NUMBER=9000"},
+			expectedIgnored: []string{"Ignoring environment variable APP_PORT__NUMBER: cannot set NUMBER in a int value"},
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, 8000, c.Port)
+			},
+		},
+		{
+			name:          "invalid number",
+			prefix:        "APP",
+			environ:       []string{"APP_PORT=eighty"},
+			expectedError: `environment variable APP_PORT: invalid value "eighty"`,
+		},
+		{
+			name:          "whole section",
+			prefix:        "APP",
+			environ:       []string{"APP_LOGGING=info"},
+			expectedError: "cannot set a struct value from the environment",
+		},
+		{
+			name:            "unexported fields are not reachable",
+			prefix:          "APP",
+			environ:         []string{"APP_SERVICECONFIGS__ABS__MERGEDCOMPONENTCONFIGS__CLIENT__TIMEOUT=1"},
+			expectedIgnored: []string{"Ignoring environment variable APP_SERVICECONFIGS__ABS__MERGEDCOMPONENTCONFIGS__CLIENT__TIMEOUT: unknown field MERGEDCOMPONENTCONFIGS"},
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, ComponentConfigs{}, c.ServiceConfigs["ABS"].MergedComponentConfigs())
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newEnvTestConfig()
+			ignored, err := applyEnvOverrides(c, tc.prefix, tc.environ)
+			if tc.expectedError != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedIgnored, ignored)
+			tc.check(t, c)
+		})
+	}
+}
+
+func TestDefaultConfigBuilder_ReadEnvOverrides(t *testing.T) {
+	t.Setenv("CONFIGTEST_DEFAULTCOMPONENTCONFIGS__CLIENT__MAXRETRIES", "5")
+	t.Setenv("CONFIGTEST_SERVICECONFIGS__ABS__URL", "https://abs.local")
+
+	builder := options{envPrefix: "CONFIGTEST"}.builder()
+	file, err := builder.Load("testdata/example_config.json")
+	require.NoError(t, err)
+	defer file.Close()
+	require.NoError(t, builder.Read(file))
+
+	abs, err := builder.GetConfig().GetServiceConfig("ABS")
+	require.NoError(t, err)
+	require.Equal(t, "https://abs.local", abs.URL)
+	// overrides are applied before the defaults are merged into the service configs
+	require.Equal(t, 5, abs.MergedComponentConfigs().Client.MaxRetries)
+}
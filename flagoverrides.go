@@ -0,0 +1,124 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Flags holds the config overrides given on the command line through the flags registered by BindFlags. Pass it to
+// New with WithFlags to apply the overrides on top of the config file, overlays and environment overrides.
+type Flags struct {
+	overrides []flagOverride
+}
+
+// flagOverride is a config value set by a command-line flag
+type flagOverride struct {
+	flagName string
+	path     []string
+	value    string
+}
+
+// pathFlag is a flag.Value recording an override of the config value at path
+type pathFlag struct {
+	flags  *Flags
+	name   string
+	path   []string
+	isBool bool
+	value  string
+}
+
+// String returns the last value set for the flag
+func (p *pathFlag) String() string {
+	return p.value
+}
+
+// Set validates the value by decoding it into an empty Config and records the override
+func (p *pathFlag) Set(value string) error {
+	if err := setPath(reflect.ValueOf(&Config{}).Elem(), p.path, value); err != nil {
+		return err
+	}
+	p.value = value
+	p.flags.overrides = append(p.flags.overrides, flagOverride{flagName: p.name, path: p.path, value: value})
+	return nil
+}
+
+// IsBoolFlag allows config flags to be given without a value, e.g. -client-insecure-skip-verify
+func (p *pathFlag) IsBoolFlag() bool {
+	return p.isBool
+}
+
+// serviceURLFlag is a flag.Value recording overrides of service URLs given as NAME=URL
+type serviceURLFlag struct {
+	flags  *Flags
+	name   string
+	values []string
+}
+
+// String returns the service URLs set so far
+func (s *serviceURLFlag) String() string {
+	return strings.Join(s.values, ",")
+}
+
+// Set records the override of the URL of the named service
+func (s *serviceURLFlag) Set(value string) error {
+	serviceName, serviceURL, found := strings.Cut(value, "=")
+	if !found || serviceName == "" {
+		return fmt.Errorf("expected NAME=URL, got %q", value)
+	}
+	s.values = append(s.values, value)
+	s.flags.overrides = append(s.flags.overrides, flagOverride{
+		flagName: s.name,
+		path:     []string{"ServiceConfigs", serviceName, "Url"},
+		value:    serviceURL,
+	})
+	return nil
+}
+
+// BindFlags registers flags on fs for the core Config fields and returns the Flags that collects their values when
+// fs is parsed. prefix is prepended to every flag name to avoid clashing with an application's own flags. The flags
+// registered are:
+//
+//	-env, -port, -log-level
+//	-client-timeout, -client-idle-conn-timeout, -client-max-idle-conns-per-host, -client-max-conns-per-host,
+//	-client-max-retries, -client-disable-compression, -client-insecure-skip-verify, -client-ca-bundle-path
+//	-service-url NAME=URL (may be repeated)
+//
+// Only flags given on the command line override the config.
+func BindFlags(fs *flag.FlagSet, prefix string) *Flags {
+	f := &Flags{}
+	bind := func(name string, isBool bool, usage string, path ...string) {
+		fs.Var(&pathFlag{flags: f, name: prefix + name, path: path, isBool: isBool}, prefix+name, usage)
+	}
+
+	bind("env", false, "environment for which this config applies", "Env")
+	bind("port", false, "port number used by this API", "Port")
+	bind("log-level", false, "logging level", "Logging", "Level")
+
+	bind("client-timeout", false, "default client timeout in seconds", "DefaultComponentConfigs", "Client", "Timeout")
+	bind("client-idle-conn-timeout", false, "default client idle connection timeout in seconds", "DefaultComponentConfigs", "Client", "IdleConnTimeout")
+	bind("client-max-idle-conns-per-host", false, "default client maximum idle connections per host", "DefaultComponentConfigs", "Client", "MaxIdleConnsPerHost")
+	bind("client-max-conns-per-host", false, "default client maximum connections per host", "DefaultComponentConfigs", "Client", "MaxConnsPerHost")
+	bind("client-max-retries", false, "default client maximum retries", "DefaultComponentConfigs", "Client", "MaxRetries")
+	bind("client-disable-compression", true, "default client disables compression", "DefaultComponentConfigs", "Client", "DisableCompression")
+	bind("client-insecure-skip-verify", true, "default client skips TLS certificate verification", "DefaultComponentConfigs", "Client", "InsecureSkipVerify")
+	bind("client-ca-bundle-path", false, "default client CA bundle path", "DefaultComponentConfigs", "Client", "CABundlePath")
+
+	fs.Var(&serviceURLFlag{flags: f, name: prefix + "service-url"}, prefix+"service-url", "override the Url of a service given as NAME=URL, may be repeated")
+
+	return f
+}
+
+// apply sets the config values given on the command line in the order the flags were given
+func (f *Flags) apply(c *Config) error {
+	if f == nil {
+		return nil
+	}
+	for _, override := range f.overrides {
+		if err := setPath(reflect.ValueOf(c).Elem(), override.path, override.value); err != nil {
+			return fmt.Errorf("flag -%v: %w", override.flagName, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindFlags(t *testing.T) {
+	testcases := []struct {
+		name               string
+		prefix             string
+		args               []string
+		check              func(t *testing.T, c *Config)
+		expectedParseError string
+		expectedApplyError string
+	}{
+		{
+			name: "no flags leaves config unchanged",
+			args: []string{},
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, newEnvTestConfig(), c)
+			},
+		},
+		{
+			name: "core fields",
+			args: []string{"-env", "local", "-port", "9000", "-log-level", "debug"},
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, "local", c.Env)
+				require.Equal(t, 9000, c.Port)
+				require.Equal(t, "debug", c.Logging.Level)
+			},
+		},
+		{
+			name: "default client settings",
+			args: []string{
+				"-client-timeout=30",
+				"-client-max-retries", "4",
+				"-client-insecure-skip-verify",
+				"-client-disable-compression=false",
+				"-client-ca-bundle-path", "other.pem",
+			},
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, 30, c.DefaultComponentConfigs.Client.Timeout)
+				require.Equal(t, 4, c.DefaultComponentConfigs.Client.MaxRetries)
+				require.Equal(t, True, c.DefaultComponentConfigs.Client.InsecureSkipVerify)
+				require.Equal(t, False, c.DefaultComponentConfigs.Client.DisableCompression)
+				require.Equal(t, "other.pem", c.DefaultComponentConfigs.Client.CABundlePath)
+			},
+		},
+		{
+			name:   "prefixed service urls, last one wins",
+			prefix: "config.",
+			args:   []string{"-config.service-url", "ABS=https://one.local", "-config.service-url", "ABS=https://two.local"},
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, "https://two.local", c.ServiceConfigs["ABS"].URL)
+			},
+		},
+		{
+			name:               "invalid number is rejected when parsing",
+			args:               []string{"-port", "eighty"},
+			expectedParseError: `invalid value "eighty" for flag -port`,
+		},
+		{
+			name:               "invalid service url flag",
+			args:               []string{"-service-url", "https://one.local"},
+			expectedParseError: "expected NAME=URL",
+		},
+		{
+			name:               "unknown service is rejected when applied",
+			args:               []string{"-service-url", "NOPE=https://one.local"},
+			expectedApplyError: "flag -service-url: no entry named NOPE",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			flags := BindFlags(fs, tc.prefix)
+
+			err := fs.Parse(tc.args)
+			if tc.expectedParseError != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedParseError)
+				return
+			}
+			require.NoError(t, err)
+
+			c := newEnvTestConfig()
+			err = flags.apply(c)
+			if tc.expectedApplyError != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedApplyError)
+				return
+			}
+			require.NoError(t, err)
+			tc.check(t, c)
+		})
+	}
+}
+
+func TestDefaultConfigBuilder_ReadFlagsPrecedence(t *testing.T) {
+	t.Setenv("CONFIGTEST_PORT", "9000")
+	t.Setenv("CONFIGTEST_ENV", "FromEnv")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := BindFlags(fs, "")
+	require.NoError(t, fs.Parse([]string{"-port", "9100", "-client-timeout", "20"}))
+
+	builder := options{envPrefix: "CONFIGTEST", flags: flags}.builder()
+	file, err := builder.Load("testdata/example_config.json")
+	require.NoError(t, err)
+	defer file.Close()
+	require.NoError(t, builder.Read(file))
+
+	c := builder.GetConfig()
+	require.Equal(t, 9100, c.Port)
+	require.Equal(t, "FromEnv", c.Env)
+	require.Equal(t, 20, c.DefaultComponentConfigs.Client.Timeout)
+	// ABS overrides the default Timeout
+	require.Equal(t, 30, c.ServiceConfigs["ABS"].MergedComponentConfigs().Client.Timeout)
+}
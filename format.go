@@ -0,0 +1,139 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format identifies the encoding used by a config file
+type Format int
+
+// Format constants, FormatAuto selects the format from the config file extension
+const (
+	FormatAuto Format = iota
+	FormatJSON
+	FormatYAML
+	FormatTOML
+)
+
+// String returns the name of the format
+func (f Format) String() string {
+	switch f {
+	case FormatJSON:
+		return "json"
+	case FormatYAML:
+		return "yaml"
+	case FormatTOML:
+		return "toml"
+	default:
+		return "auto"
+	}
+}
+
+// formatFromPath selects a format using the extension of path, defaulting to JSON
+func formatFromPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatJSON
+	}
+}
+
+// resolveFormat returns format unless it is FormatAuto in which case the format is
+// selected from the path
+func resolveFormat(format Format, path string) Format {
+	if format == FormatAuto {
+		return formatFromPath(path)
+	}
+	return format
+}
+
+// toJSON converts config data of the given format into JSON so every format is
+// decoded by the same strict json.Decoder, keeping the list to map behavior of
+// ServicesMap, DatabasesMap and EndpointMap and the rejection of unknown fields.
+// JSON may carry comments and trailing commas.
+func toJSON(data []byte, format Format) ([]byte, error) {
+	switch format {
+	case FormatJSON, FormatAuto:
+		return stripJSONC(data, true)
+	case FormatYAML:
+		return yamlToJSON(data)
+	case FormatTOML:
+		return tomlToJSON(data)
+	default:
+		return nil, fmt.Errorf("unsupported config format %v", format)
+	}
+}
+
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil { // empty document
+		return []byte("{}"), nil
+	}
+
+	normalized, err := normalizeYAML(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(normalized)
+}
+
+// normalizeYAML converts any map[interface{}]interface{} produced by the yaml
+// decoder into map[string]interface{} so it can be marshalled as JSON
+func normalizeYAML(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			normalized, err := normalizeYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = normalized
+		}
+		return v, nil
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			keyStr, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("unsupported non-string yaml key %v", key)
+			}
+			normalized, err := normalizeYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			m[keyStr] = normalized
+		}
+		return m, nil
+	case []interface{}:
+		for i, item := range v {
+			normalized, err := normalizeYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = normalized
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+func tomlToJSON(data []byte) ([]byte, error) {
+	doc := map[string]interface{}{}
+	if _, err := toml.Decode(string(data), &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"crypto/md5"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_resolveFormat(t *testing.T) {
+	testcases := []struct {
+		name     string
+		format   Format
+		path     string
+		expected Format
+	}{
+		{
+			name:     "auto json extension",
+			format:   FormatAuto,
+			path:     "testdata/example_config.json",
+			expected: FormatJSON,
+		},
+		{
+			name:     "auto yaml extension",
+			format:   FormatAuto,
+			path:     "testdata/example_config.yaml",
+			expected: FormatYAML,
+		},
+		{
+			name:     "auto yml extension is case insensitive",
+			format:   FormatAuto,
+			path:     "config.YML",
+			expected: FormatYAML,
+		},
+		{
+			name:     "auto toml extension",
+			format:   FormatAuto,
+			path:     "testdata/example_config.toml",
+			expected: FormatTOML,
+		},
+		{
+			name:     "auto unknown extension defaults to json",
+			format:   FormatAuto,
+			path:     "config",
+			expected: FormatJSON,
+		},
+		{
+			name:     "explicit format wins over extension",
+			format:   FormatYAML,
+			path:     "config.json",
+			expected: FormatYAML,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, resolveFormat(tc.format, tc.path))
+		})
+	}
+}
+
+func TestDefaultConfigBuilder_ReadFormats(t *testing.T) {
+	jsonBuilder := defaultConfigBuilder{}
+	jsonFile, err := jsonBuilder.Load("testdata/example_config.json")
+	require.NoError(t, err)
+	defer jsonFile.Close()
+	require.NoError(t, jsonBuilder.Read(jsonFile))
+
+	for _, path := range []string{"testdata/example_config.yaml", "testdata/example_config.toml"} {
+		t.Run(path, func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+
+			builder := defaultConfigBuilder{}
+			file, err := builder.Load(path)
+			require.NoError(t, err)
+			defer file.Close()
+			require.NoError(t, builder.Read(file))
+
+			config := builder.GetConfig()
+			require.Equal(t, fmt.Sprintf("%x", md5.Sum(data)), config.Hash)
+
+			require.Equal(t, []string{path}, config.files)
+
+			config.Hash, config.files = jsonBuilder.GetConfig().Hash, jsonBuilder.GetConfig().files
+			require.Equal(t, jsonBuilder.GetConfig(), config)
+		})
+	}
+}
+
+func TestDefaultConfigBuilder_ReadFormatErrors(t *testing.T) {
+	testcases := []struct {
+		name          string
+		format        Format
+		data          string
+		expectedError string
+	}{
+		{
+			name:          "yaml unknown fields are rejected",
+			format:        FormatYAML,
+			data:          "Env: Dev\nOopsBadField: 123\n",
+			expectedError: "Error decoding config data json: unknown field",
+		},
+		{
+			name:          "invalid yaml",
+			format:        FormatYAML,
+			data:          "Env: [Dev\n",
+			expectedError: "Error converting yaml config data",
+		},
+		{
+			name:          "toml unknown fields are rejected",
+			format:        FormatTOML,
+			data:          "Env = \"Dev\"\nOopsBadField = 123\n",
+			expectedError: "Error decoding config data json: unknown field",
+		},
+		{
+			name:          "invalid toml",
+			format:        FormatTOML,
+			data:          "Env = \n",
+			expectedError: "Error converting toml config data",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			builder := defaultConfigBuilder{format: tc.format}
+			err := builder.Read(strings.NewReader(tc.data))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.expectedError)
+			require.Nil(t, builder.GetConfig())
+		})
+	}
+}
+
+func TestDefaultConfigBuilder_ReadEmptyYAML(t *testing.T) {
+	builder := defaultConfigBuilder{format: FormatYAML}
+	require.NoError(t, builder.Read(strings.NewReader("")))
+	require.NotNil(t, builder.GetConfig())
+}
+
+func Test_configFlagUnmarshalJSON(t *testing.T) {
+	testcases := []struct {
+		name          string
+		data          string
+		expected      configFlag
+		expectedError bool
+	}{
+		{name: "true", data: `true`, expected: True},
+		{name: "false", data: `false`, expected: False},
+		{name: "int true", data: `2`, expected: True},
+		{name: "int false", data: `1`, expected: False},
+		{name: "int unset", data: `0`, expected: UnSet},
+		{name: "null leaves unset", data: `null`, expected: UnSet},
+		{name: "string is rejected", data: `"true"`, expectedError: true},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var flag configFlag
+			err := flag.UnmarshalJSON([]byte(tc.data))
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, flag)
+		})
+	}
+}
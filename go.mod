@@ -3,6 +3,7 @@ module github.com/CodeNamor/Config
 go 1.20
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/CodeNamor/Common v0.1.0
 	github.com/CodeNamor/http v0.1.3
 	github.com/imdario/mergo v0.3.8
@@ -10,6 +11,7 @@ require (
 	github.com/kr/pretty v0.3.1
 	github.com/sirupsen/logrus v1.9.3
 	github.com/stretchr/testify v1.9.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -25,5 +27,4 @@ require (
 	github.com/rogpeppe/go-internal v1.12.0 // indirect
 	github.com/sethgrid/pester v1.2.0 // indirect
 	golang.org/x/sys v0.18.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// HistoryEntry describes a config kept by a Watcher
+type HistoryEntry struct {
+	Hash     string
+	LoadedAt time.Time
+	// Current is true for the config the Watcher is using
+	Current bool
+}
+
+// historyEntry is a config kept for Rollback
+type historyEntry struct {
+	config   *Config
+	loadedAt time.Time
+}
+
+// History lists the configs that Rollback can return to, most recently loaded first
+func (w *Watcher) History() []HistoryEntry {
+	current := w.store.snapshot()
+
+	w.historyMu.Lock()
+	defer w.historyMu.Unlock()
+	entries := make([]HistoryEntry, len(w.history))
+	for i, entry := range w.history {
+		entries[i] = HistoryEntry{
+			Hash:     entry.config.Hash,
+			LoadedAt: entry.loadedAt,
+			Current:  entry.config == current,
+		}
+	}
+	return entries
+}
+
+// Rollback makes the kept config with the given hash the current config again, without reading the config files.
+// OnChange subscribers are notified as they are for a reload. The config files are not changed, so the next change
+// to them, or a call to Reload, loads them again. Auth keys are those the config was loaded with. It returns
+// ErrStopped once Stop was called.
+func (w *Watcher) Rollback(hash string) error {
+	defer w.notify()
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+
+	if w.ctx.Err() != nil {
+		return ErrStopped
+	}
+
+	c := w.remembered(hash)
+	if c == nil {
+		return fmt.Errorf("no config with hash %v in the history", hash)
+	}
+	if c == w.store.snapshot() {
+		return nil
+	}
+
+	old := w.store.Swap(c)
+	w.options.logger.Info("Rolled back config file " + w.configPath + " to hash " + hash)
+	w.queueChange(old, c)
+	w.retire(old)
+	return nil
+}
+
+// remember adds the newly loaded c to the history, replacing any config with the same hash and dropping the oldest
+// configs beyond HistorySize
+func (w *Watcher) remember(c *Config) {
+	w.historyMu.Lock()
+	defer w.historyMu.Unlock()
+
+	history := []historyEntry{{config: c, loadedAt: time.Now()}}
+	for _, entry := range w.history {
+		if entry.config.Hash != c.Hash && len(history) < w.settings.HistorySize {
+			history = append(history, entry)
+		}
+	}
+	w.history = history
+}
+
+// remembered returns the kept config with the given hash, nil when there is none
+func (w *Watcher) remembered(hash string) *Config {
+	w.historyMu.Lock()
+	defer w.historyMu.Unlock()
+
+	for _, entry := range w.history {
+		if entry.config.Hash == hash {
+			return entry.config
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher_History(t *testing.T) {
+	configPath := newWatchedConfig(t, watchedPort("8000"))
+	w, errs := NewWatcher(configPath, WatchSettings{Interval: -1, HistorySize: 3}, WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+	defer w.Stop()
+
+	hashes := []string{w.Config().Hash}
+	for _, port := range []string{"8001", "8002", "8003"} {
+		replaceFile(t, configPath, watchedPort(port))
+		require.NoError(t, w.Reload())
+		hashes = append(hashes, w.Config().Hash)
+	}
+
+	// a failed reload is not kept
+	replaceFile(t, configPath, `{"Port": "nope"}`)
+	require.Error(t, w.Reload())
+
+	history := w.History()
+	require.Len(t, history, 3, "only HistorySize configs are kept")
+	require.Equal(t, []string{hashes[3], hashes[2], hashes[1]}, []string{history[0].Hash, history[1].Hash, history[2].Hash})
+	require.Equal(t, []bool{true, false, false}, []bool{history[0].Current, history[1].Current, history[2].Current})
+	require.False(t, history[0].LoadedAt.Before(history[1].LoadedAt))
+
+	// reloading a config already kept moves it to the front rather than keeping it twice
+	replaceFile(t, configPath, watchedPort("8002"))
+	require.NoError(t, w.Reload())
+	history = w.History()
+	require.Equal(t, []string{hashes[2], hashes[3], hashes[1]}, []string{history[0].Hash, history[1].Hash, history[2].Hash})
+}
+
+func TestWatcher_Rollback(t *testing.T) {
+	configPath := newWatchedConfig(t, watchedPort("8000"))
+	w, errs := NewWatcher(configPath, WatchSettings{Interval: -1}, WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+	defer w.Stop()
+	original := w.Config()
+
+	replaceFile(t, configPath, watchedPort("9000"))
+	require.NoError(t, w.Reload())
+	require.Equal(t, 9000, w.Config().Port)
+
+	recorder := &changeRecorder{}
+	w.OnChange(recorder.record)
+
+	require.NoError(t, w.Rollback(original.Hash))
+	require.Equal(t, original.Hash, w.Config().Hash)
+	_, _, diffs := recorder.changes()
+	require.Len(t, diffs, 1)
+	require.Equal(t, []FieldChange{{Field: "Port", Old: 9000, New: 8000}}, diffs[0].Fields)
+	require.True(t, w.History()[1].Current)
+
+	// the files did not change so the rolled back config stays in use
+	w.reloadIfChanged()
+	require.Equal(t, original.Hash, w.Config().Hash)
+
+	require.NoError(t, w.Rollback(original.Hash), "rolling back to the current config does nothing")
+	_, _, diffs = recorder.changes()
+	require.Len(t, diffs, 1)
+
+	require.EqualError(t, w.Rollback("unknown"), "no config with hash unknown in the history")
+}
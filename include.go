@@ -0,0 +1,237 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	cnErrors "github.com/CodeNamor/Common/errors"
+	compath "github.com/CodeNamor/Common/path"
+)
+
+// includeKey is the key of a list element replaced by the content of other config files
+const includeKey = "$include"
+
+// includer expands the include directives of config sources
+type includer struct {
+	b *defaultConfigBuilder
+	// stack holds the files being expanded, to detect include cycles
+	stack []string
+	// files are the included files in the order they were read
+	files []string
+}
+
+// listElement is an element of an expanded list along with the file it came from
+type listElement struct {
+	value  interface{}
+	origin string
+}
+
+// expandIncludes replaces the include directives found in the lists of each source by the content of the files they
+// match. A directive is a list element holding only the include key, with a glob pattern or a list of them:
+//
+//	"ServiceConfigs": [
+//	  { "$include": "services/*.json" },
+//	  { "Name": "ABS", "Url": "https://abs.local" }
+//	]
+//
+// Patterns are resolved relative to the file holding the directive, matching files are read in lexical order and
+// may hold a single object or a list of them, in any supported format. Included files may include others. It returns
+// the expanded sources, as JSON, and the included files.
+func (b *defaultConfigBuilder) expandIncludes(sources []configSource, dirs []string) ([]configSource, []string, error) {
+	in := &includer{b: b}
+	expanded := make([]configSource, len(sources))
+	for i, src := range sources {
+		expanded[i] = src
+		if !bytes.Contains(src.data, []byte(includeKey)) {
+			continue
+		}
+
+		tree, err := decodeTree(src)
+		if err != nil {
+			return nil, nil, cnErrors.WithErrorAndCause(err, "Error converting "+src.format.String()+" config data "+src.path)
+		}
+		in.stack = []string{path.Clean(src.path)}
+		tree, err = in.expand(tree, dirs[i], sourceName(src.path), "")
+		if err != nil {
+			return nil, nil, cnErrors.WithErrorAndCause(err, "Error including config files in "+sourceName(src.path))
+		}
+		data, err := json.Marshal(tree)
+		if err != nil {
+			return nil, nil, cnErrors.WithErrorAndCause(err, "Error including config files in "+sourceName(src.path))
+		}
+		expanded[i] = configSource{path: src.path, format: FormatJSON, data: data, expanded: true}
+	}
+	return expanded, in.files, nil
+}
+
+// expand expands the include directives in value, which was read from origin in dir. keyPath is the path of value in
+// the config, made of keys as namedListPaths are.
+func (in *includer) expand(value interface{}, dir string, origin string, keyPath string) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			expanded, err := in.expand(item, dir, origin, joinPath(keyPath, k))
+			if err != nil {
+				return nil, err
+			}
+			v[k] = expanded
+		}
+		return v, nil
+	case []interface{}:
+		return in.expandList(v, dir, origin, keyPath)
+	default:
+		return value, nil
+	}
+}
+
+// expandList replaces the include directives of the list, checking the names of named lists are unique
+func (in *includer) expandList(list []interface{}, dir string, origin string, keyPath string) ([]interface{}, error) {
+	elements := make([]listElement, 0, len(list))
+	included := false
+	for _, item := range list {
+		patterns, isDirective, err := includePatterns(item)
+		if err != nil {
+			return nil, err
+		}
+		if !isDirective {
+			expanded, err := in.expand(item, dir, origin, keyPath)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, listElement{value: expanded, origin: origin})
+			continue
+		}
+
+		included = true
+		for _, pattern := range patterns {
+			includedElements, err := in.include(dir, pattern, keyPath)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, includedElements...)
+		}
+	}
+
+	if included && isNamedListPath(keyPath) {
+		if err := checkUniqueNames(keyPath, elements); err != nil {
+			return nil, err
+		}
+	}
+
+	expanded := make([]interface{}, len(elements))
+	for i, element := range elements {
+		expanded[i] = element.value
+	}
+	return expanded, nil
+}
+
+// include reads the files matching pattern and returns the list elements they hold, for the list at keyPath
+func (in *includer) include(dir string, pattern string, keyPath string) ([]listElement, error) {
+	files, err := in.b.glob(compath.Resolve(dir, pattern))
+	if err != nil {
+		return nil, fmt.Errorf("invalid include pattern %v: %w", pattern, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files match include pattern %v", pattern)
+	}
+
+	var elements []listElement
+	for _, file := range files {
+		for _, including := range in.stack {
+			if including == file {
+				return nil, fmt.Errorf("include cycle %v -> %v", strings.Join(in.stack, " -> "), file)
+			}
+		}
+
+		data, err := in.b.readFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading included file %v: %w", file, err)
+		}
+		in.files = append(in.files, file)
+		tree, err := decodeTree(configSource{path: file, format: formatFromPath(file), data: data})
+		if err != nil {
+			return nil, fmt.Errorf("error decoding included file %v: %w", file, err)
+		}
+
+		items, isList := tree.([]interface{})
+		if !isList {
+			items = []interface{}{tree}
+		}
+
+		in.stack = append(in.stack, file)
+		for _, item := range items {
+			if _, isObject := item.(map[string]interface{}); !isObject {
+				return nil, fmt.Errorf("included file %v must hold an object or a list of objects", file)
+			}
+			expanded, err := in.expand(item, path.Dir(file), file, keyPath)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, listElement{value: expanded, origin: file})
+		}
+		in.stack = in.stack[:len(in.stack)-1]
+	}
+	return elements, nil
+}
+
+// includePatterns returns the patterns of item when it is an include directive
+func includePatterns(item interface{}) (patterns []string, isDirective bool, err error) {
+	m, ok := item.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return nil, false, nil
+	}
+	value, ok := m[includeKey]
+	if !ok {
+		return nil, false, nil
+	}
+
+	switch v := value.(type) {
+	case string:
+		return []string{v}, true, nil
+	case []interface{}:
+		for _, pattern := range v {
+			s, ok := pattern.(string)
+			if !ok {
+				return nil, true, fmt.Errorf("%v patterns must be strings", includeKey)
+			}
+			patterns = append(patterns, s)
+		}
+		return patterns, true, nil
+	default:
+		return nil, true, fmt.Errorf("%v must be a pattern or a list of patterns", includeKey)
+	}
+}
+
+// checkUniqueNames reports elements of the named list at keyPath sharing a Name, along with the files defining them
+func checkUniqueNames(keyPath string, elements []listElement) error {
+	origins := make(map[string]string, len(elements))
+	for _, element := range elements {
+		name, _ := elementName(element.value)
+		if first, exists := origins[name]; exists {
+			return fmt.Errorf("duplicate %v name %q in %v and %v", keyPath, name, first, element.origin)
+		}
+		origins[name] = element.origin
+	}
+	return nil
+}
+
+// glob returns the files of the builder filesystem matching pattern, in lexical order
+func (b *defaultConfigBuilder) glob(pattern string) ([]string, error) {
+	if b.fsys != nil {
+		return fs.Glob(b.fsys, pattern)
+	}
+	return filepath.Glob(pattern)
+}
+
+// sourceName names a config source in errors, config data read from memory has no path
+func sourceName(sourcePath string) string {
+	if sourcePath == "" {
+		return "config data"
+	}
+	return sourcePath
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncludes(t *testing.T) {
+	testcases := []struct {
+		name             string
+		files            map[string]string
+		expectedServices map[string]string
+		expectedFiles    []string
+		expectedError    string
+	}{
+		{
+			name: "services from several files in lexical order",
+			files: map[string]string{
+				"conf/config.json": `{"ServiceConfigs": [
+  {"$include": "services/*.json"},
+  {"Name": "INLINE", "Url": "https://inline.local"}
+]}`,
+				"conf/services/b.json": `[{"Name": "CLAIMS", "Url": "https://claims.local"}, {"Name": "MEMBERS", "Url": "https://members.local"}]`,
+				"conf/services/a.json": `{"Name": "ABS", "Url": "https://abs.local"}`,
+				"conf/services/c.yaml": `Name: IGNORED`,
+			},
+			expectedServices: map[string]string{
+				"ABS":     "https://abs.local",
+				"CLAIMS":  "https://claims.local",
+				"MEMBERS": "https://members.local",
+				"INLINE":  "https://inline.local",
+			},
+			expectedFiles: []string{"conf/config.json", "conf/services/a.json", "conf/services/b.json"},
+		},
+		{
+			name: "several patterns, other formats and nested includes",
+			files: map[string]string{
+				"conf/config.json":        `{"ServiceConfigs": [{"$include": ["abs.yaml", "more/*.json"]}]}`,
+				"conf/abs.yaml":           "Name: ABS\nUrl: https://abs.local\nEndPoints:\n  - $include: endpoints/abs.json\n",
+				"conf/endpoints/abs.json": `{"Name": "ClaimStatus", "Path": "/claims"}`,
+				"conf/more/claims.json":   `{"Name": "CLAIMS", "Url": "https://claims.local"}`,
+			},
+			expectedServices: map[string]string{
+				"ABS":    "https://abs.local",
+				"CLAIMS": "https://claims.local",
+			},
+			expectedFiles: []string{"conf/config.json", "conf/abs.yaml", "conf/endpoints/abs.json", "conf/more/claims.json"},
+		},
+		{
+			name: "duplicate names across files",
+			files: map[string]string{
+				"conf/config.json":     `{"ServiceConfigs": [{"Name": "ABS"}, {"$include": "services/*.json"}]}`,
+				"conf/services/a.json": `{"Name": "CLAIMS"}`,
+				"conf/services/b.json": `{"Name": "ABS"}`,
+			},
+			expectedError: `Error including config files in conf/config.json duplicate ServiceConfigs name "ABS" in conf/config.json and conf/services/b.json`,
+		},
+		{
+			name: "include cycle",
+			files: map[string]string{
+				"conf/config.json":    `{"ServiceConfigs": [{"$include": "abs.json"}]}`,
+				"conf/abs.json":       `{"Name": "ABS", "EndPoints": [{"$include": "endpoints.json"}]}`,
+				"conf/endpoints.json": `{"Name": "ClaimStatus", "Children": [{"$include": "abs.json"}]}`,
+			},
+			expectedError: "Error including config files in conf/config.json include cycle conf/config.json -> conf/abs.json -> conf/endpoints.json -> conf/abs.json",
+		},
+		{
+			name: "pattern matching nothing",
+			files: map[string]string{
+				"conf/config.json": `{"ServiceConfigs": [{"$include": "services/*.json"}]}`,
+			},
+			expectedError: "Error including config files in conf/config.json no files match include pattern services/*.json",
+		},
+		{
+			name: "included file holding a value that is not an object",
+			files: map[string]string{
+				"conf/config.json": `{"ServiceConfigs": [{"$include": "abs.json"}]}`,
+				"conf/abs.json":    `["ABS"]`,
+			},
+			expectedError: "Error including config files in conf/config.json included file conf/abs.json must hold an object or a list of objects",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for name, data := range tc.files {
+				fsys[name] = &fstest.MapFile{Data: []byte(data)}
+			}
+
+			c, errs := NewFromFS(fsys, "conf/config.json", WithRetryClientBuilder(httpClientBuilder))
+			if tc.expectedError != "" {
+				require.Nil(t, c)
+				require.Len(t, errs, 1)
+				require.EqualError(t, errs[0], tc.expectedError)
+				return
+			}
+			require.Empty(t, errs)
+
+			services := map[string]string{}
+			for name, service := range c.ServiceConfigs {
+				services[name] = service.URL
+			}
+			require.Equal(t, tc.expectedServices, services)
+			require.Equal(t, tc.expectedFiles, c.files)
+		})
+	}
+}
+
+func TestIncludes_HashCoversIncludedFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config.json": {Data: []byte(`{"ServiceConfigs": [{"$include": "abs.json"}]}`)},
+		"abs.json":    {Data: []byte(`{"Name": "ABS", "Url": "https://abs.local"}`)},
+	}
+	first, errs := NewFromFS(fsys, "config.json", WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+
+	fsys["abs.json"] = &fstest.MapFile{Data: []byte(`{"Name": "ABS", "Url": "https://abs.remote"}`)}
+	second, errs := NewFromFS(fsys, "config.json", WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+	require.NotEqual(t, first.Hash, second.Hash)
+}
+
+func TestWatcher_ReloadsOnIncludedFileChange(t *testing.T) {
+	configPath := newWatchedConfig(t, `{"ServiceConfigs": [{"$include": "services/*.json"}]}`)
+	servicePath := filepath.Join(filepath.Dir(configPath), "services", "abs.json")
+	require.NoError(t, os.Mkdir(filepath.Dir(servicePath), 0o700))
+	require.NoError(t, os.WriteFile(servicePath, []byte(`{"Name": "ABS", "Url": "https://abs.local"}`), 0o600))
+
+	w, errs := NewWatcher(configPath, WatchSettings{Interval: -1}, WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+	defer w.Stop()
+
+	replaceFile(t, servicePath, `{"Name": "ABS", "Url": "https://abs.remote"}`)
+	w.reloadIfChanged()
+	require.Equal(t, "https://abs.remote", w.Config().ServiceConfigs["ABS"].URL)
+}
@@ -0,0 +1,243 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// refPrefix starts an interpolation that refers to another config value
+const refPrefix = "ref:"
+
+// configLeaf is a value of the decoded config that can be interpolated or referred to
+type configLeaf struct {
+	// path is the dotted path to the value, using the names found in config files and the map keys
+	path  string
+	value string
+	// set replaces the value in the config, nil for values that are not strings
+	set func(string)
+
+	resolving bool
+	done      bool
+	err       error
+}
+
+// interpolator resolves ${...} expressions in the string values of a config
+type interpolator struct {
+	lookupEnv func(string) (string, bool)
+	// leaves are keyed by their path
+	leaves map[string]*configLeaf
+	// folded lists the leaves by lower cased path, for references that do not match a path exactly
+	folded map[string][]*configLeaf
+}
+
+// interpolate resolves the expressions in every string value of c:
+//
+//	${NAME}              the value of the environment variable NAME, which must be set
+//	${NAME:-fallback}    the value of NAME, or fallback when NAME is unset or empty
+//	${ref:Path.To.Value} the value found at the path in the config, e.g. ${ref:ServiceConfigs.ABS.Url}
+//	$${                  a literal ${
+//
+// Referenced values are interpolated first, references that lead back to themselves are reported as cycles. A
+// reference matches a path ignoring case when no path matches it exactly and only one path matches it that way. Every
+// value that fails to resolve is reported with its path.
+func interpolate(c *Config, lookupEnv func(string) (string, bool)) error {
+	in := &interpolator{
+		lookupEnv: lookupEnv,
+		leaves:    make(map[string]*configLeaf),
+		folded:    make(map[string][]*configLeaf),
+	}
+	in.collect("", reflect.ValueOf(c).Elem(), nil)
+
+	paths := make([]string, 0, len(in.leaves))
+	for path, leaf := range in.leaves {
+		paths = append(paths, path)
+		folded := strings.ToLower(path)
+		in.folded[folded] = append(in.folded[folded], leaf)
+	}
+	sort.Strings(paths)
+
+	var errs []error
+	for _, path := range paths {
+		leaf := in.leaves[path]
+		if !leaf.done && leaf.err == nil {
+			_, _ = in.resolve(leaf, nil)
+		}
+		if leaf.err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", leaf.path, leaf.err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// collect records the leaves found in v, set replaces v when it is not addressable
+func (in *interpolator) collect(path string, v reflect.Value, set func(reflect.Value)) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			in.collect(path, v.Elem(), v.Elem().Set)
+		}
+	case reflect.Interface:
+		if !v.IsNil() {
+			in.collect(path, v.Elem(), set)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" { // unexported
+				continue
+			}
+			jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if jsonName == "-" {
+				continue
+			}
+			name := field.Name
+			if jsonName != "" {
+				name = jsonName
+			}
+			in.collect(joinPath(path, name), v.Field(i), v.Field(i).Set)
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			key := key
+			in.collect(joinPath(path, key.String()), v.MapIndex(key), func(value reflect.Value) {
+				v.SetMapIndex(key, value)
+			})
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			in.collect(joinPath(path, strconv.Itoa(i)), v.Index(i), v.Index(i).Set)
+		}
+	case reflect.String:
+		t := v.Type()
+		in.leaves[path] = &configLeaf{
+			path:  path,
+			value: v.String(),
+			set: func(value string) {
+				set(reflect.ValueOf(value).Convert(t))
+			},
+		}
+	default:
+		if v.IsValid() && path != "" {
+			in.leaves[path] = &configLeaf{path: path, value: fmt.Sprint(v.Interface()), done: true}
+		}
+	}
+}
+
+// resolve interpolates the leaf, chain holds the paths of the references being resolved
+func (in *interpolator) resolve(leaf *configLeaf, chain []string) (string, error) {
+	if leaf.done {
+		return leaf.value, nil
+	}
+	if leaf.err != nil {
+		return "", leaf.err
+	}
+	if leaf.resolving {
+		return "", fmt.Errorf("reference cycle %v", strings.Join(append(chain, leaf.path), " -> "))
+	}
+
+	leaf.resolving = true
+	value, err := in.expand(leaf.value, append(chain, leaf.path))
+	leaf.resolving = false
+	if err != nil {
+		leaf.err = err
+		return "", err
+	}
+
+	if value != leaf.value {
+		leaf.set(value)
+	}
+	leaf.value, leaf.done = value, true
+	return value, nil
+}
+
+// expand replaces the expressions in s
+func (in *interpolator) expand(s string, chain []string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], "$${"):
+			b.WriteString("${")
+			i += 3
+		case strings.HasPrefix(s[i:], "${"):
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated %q", s[i:])
+			}
+			value, err := in.evaluate(s[i+2:i+2+end], chain)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i += 2 + end + 1
+		default:
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+	return b.String(), nil
+}
+
+// evaluate returns the value of the expression found between ${ and }
+func (in *interpolator) evaluate(expression string, chain []string) (string, error) {
+	if strings.HasPrefix(expression, refPrefix) {
+		path := strings.TrimPrefix(expression, refPrefix)
+		leaf, err := in.lookup(path)
+		if err != nil {
+			return "", fmt.Errorf("${%v}: %w", expression, err)
+		}
+		value, err := in.resolve(leaf, chain)
+		if err != nil {
+			return "", fmt.Errorf("${%v}: %w", expression, err)
+		}
+		return value, nil
+	}
+
+	name, fallback, hasFallback := strings.Cut(expression, ":-")
+	if name == "" {
+		return "", fmt.Errorf("${%v}: empty variable name", expression)
+	}
+	value, ok := in.lookupEnv(name)
+	if hasFallback && value == "" {
+		return fallback, nil
+	}
+	if !ok {
+		return "", fmt.Errorf("${%v}: environment variable %v is not set", expression, name)
+	}
+	return value, nil
+}
+
+// lookup returns the leaf a reference refers to
+func (in *interpolator) lookup(path string) (*configLeaf, error) {
+	if leaf, ok := in.leaves[path]; ok {
+		return leaf, nil
+	}
+	matches := in.folded[strings.ToLower(path)]
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("unknown field %v", path)
+	case 1:
+		return matches[0], nil
+	}
+	names := make([]string, len(matches))
+	for i, leaf := range matches {
+		names[i] = leaf.path
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("%v matches %v, use the exact case", path, strings.Join(names, " and "))
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
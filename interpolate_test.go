@@ -0,0 +1,149 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolate(t *testing.T) {
+	env := map[string]string{"HOST": "internal.example.com", "EMPTY": ""}
+	lookupEnv := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	testcases := []struct {
+		name           string
+		data           string
+		check          func(t *testing.T, c *Config)
+		expectedErrors []string
+	}{
+		{
+			name: "environment variables and fallbacks",
+			data: `{
+  "Env": "${ENV_NAME:-dev}",
+  "Logging": {"GrayLogURL": "${HOST}:12201", "Level": "${EMPTY:-info}"},
+  "ServiceConfigs": [{"Name": "ABS", "Url": "https://abs.${HOST}/v1"}]
+}`,
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, "dev", c.Env)
+				require.Equal(t, "internal.example.com:12201", c.Logging.GrayLogURL)
+				require.Equal(t, "info", c.Logging.Level)
+				require.Equal(t, "https://abs.internal.example.com/v1", c.ServiceConfigs["ABS"].URL)
+			},
+		},
+		{
+			name: "references to other values",
+			data: `{
+  "Port": 8000,
+  "DefaultComponentConfigs": {"Client": {"CABundlePath": "certs/${ref:Options.bundle}"}},
+  "ServiceConfigs": [
+    {"Name": "ABS", "Url": "https://${HOST}:${ref:port}",
+     "ComponentConfigOverrides": {"Client": {"CABundlePath": "${ref:DefaultComponentConfigs.Client.CABundlePath}"}}},
+    {"Name": "CLAIMS", "Url": "${ref:ServiceConfigs.ABS.Url}/claims"}
+  ],
+  "Options": {"bundle": "ca.pem", "nested": {"urls": ["${ref:ServiceConfigs.CLAIMS.Url}"]}}
+}`,
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, "certs/ca.pem", c.DefaultComponentConfigs.Client.CABundlePath)
+				require.Equal(t, "https://internal.example.com:8000", c.ServiceConfigs["ABS"].URL)
+				require.Equal(t, "certs/ca.pem", c.ServiceConfigs["ABS"].ComponentConfigOverrides.Client.CABundlePath)
+				require.Equal(t, "https://internal.example.com:8000/claims", c.ServiceConfigs["CLAIMS"].URL)
+				require.Equal(t, map[string]interface{}{"urls": []interface{}{"https://internal.example.com:8000/claims"}}, c.Options["nested"])
+			},
+		},
+		{
+			name: "keys differing only by case keep their values",
+			data: `{"Env": "${ref:options.COPY}", "Options": {"Mode": "a", "mode": "b", "copy": "${ref:Options.mode}"}}`,
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, "b", c.Env)
+				require.Equal(t, map[string]interface{}{"Mode": "a", "mode": "b", "copy": "b"}, c.Options)
+			},
+		},
+		{
+			name: "escaped and plain dollar signs are kept",
+			data: `{"Env": "$${HOST} costs $5", "AuthServiceConfig": {"Pwd": "pa$$word"}}`,
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, "${HOST} costs $5", c.Env)
+				require.Equal(t, "pa$$word", c.AuthServiceConfig.Pwd)
+			},
+		},
+		{
+			name: "unresolved values are named",
+			data: `{
+  "Env": "${MISSING}",
+  "Logging": {"Level": "${ref:Logging.Nope}"},
+  "ServiceConfigs": [{"Name": "ABS", "Url": "https://${HOST"}]
+}`,
+			expectedErrors: []string{
+				"Env: ${MISSING}: environment variable MISSING is not set",
+				"Logging.Level: ${ref:Logging.Nope}: unknown field Logging.Nope",
+				`ServiceConfigs.ABS.Url: unterminated "${HOST"`,
+			},
+		},
+		{
+			name: "ambiguous references",
+			data: `{"Env": "${ref:Options.MODE}", "Options": {"Mode": "a", "mode": "b"}}`,
+			expectedErrors: []string{
+				"Env: ${ref:Options.MODE}: Options.MODE matches Options.Mode and Options.mode, use the exact case",
+			},
+		},
+		{
+			name: "reference cycles",
+			data: `{"Env": "${ref:Logging.Level}", "Logging": {"Level": "${ref:Env}"}, "Options": {"self": "${ref:Options.self}"}}`,
+			expectedErrors: []string{
+				"Env: ${ref:Logging.Level}: ${ref:Env}: reference cycle Env -> Logging.Level -> Env",
+				"Logging.Level: ${ref:Env}: reference cycle Env -> Logging.Level -> Env",
+				"Options.self: ${ref:Options.self}: reference cycle Options.self -> Options.self",
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Config{}
+			require.NoError(t, json.Unmarshal([]byte(tc.data), c))
+
+			err := interpolate(c, lookupEnv)
+			if tc.expectedErrors != nil {
+				require.Error(t, err)
+				require.Equal(t, tc.expectedErrors, errorStrings(err.(interface{ Unwrap() []error }).Unwrap()))
+				return
+			}
+			require.NoError(t, err)
+			tc.check(t, c)
+		})
+	}
+}
+
+func TestNewFromBytes_Interpolation(t *testing.T) {
+	t.Setenv("INTERPOLATION_TEST_HOST", "abs.local")
+	c, errs := NewFromBytes([]byte(`{"ServiceConfigs": [{"Name": "ABS", "Url": "https://${INTERPOLATION_TEST_HOST}"}]}`), "",
+		WithInterpolation(), WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+	require.Equal(t, "https://abs.local", c.ServiceConfigs["ABS"].URL)
+
+	_, errs = NewFromBytes([]byte(`{"Env": "${INTERPOLATION_TEST_MISSING}"}`), "", WithInterpolation())
+	require.Len(t, errs, 1)
+	require.EqualError(t, errs[0], "Error interpolating config values Env: ${INTERPOLATION_TEST_MISSING}: environment variable INTERPOLATION_TEST_MISSING is not set")
+}
+
+func TestNewFromBytes_InterpolationIsOptIn(t *testing.T) {
+	c, errs := NewFromBytes([]byte(`{"AuthServiceConfig": {"Pwd": "pa${word}"}}`), "")
+	require.Empty(t, errs)
+	require.Equal(t, "pa${word}", c.AuthServiceConfig.Pwd)
+}
+
+func TestNewFromBytes_InterpolationAfterOverrides(t *testing.T) {
+	t.Setenv("INTERPOLATION_TEST_HOST", "abs.local")
+	t.Setenv("INTERPOLATION_OVERRIDE_PORT", "9000")
+	t.Setenv("INTERPOLATION_OVERRIDE_ENV", "${INTERPOLATION_TEST_HOST}")
+
+	data := `{"Port": 8000, "Options": {"address": "${INTERPOLATION_TEST_HOST}:${ref:Port}"}}`
+	c, errs := NewFromBytes([]byte(data), "", WithInterpolation(), WithEnvPrefix("INTERPOLATION_OVERRIDE"),
+		WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+	require.Equal(t, "abs.local:9000", c.Options["address"], "references see overridden values")
+	require.Equal(t, "abs.local", c.Env, "overridden values are interpolated")
+}
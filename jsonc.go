@@ -0,0 +1,100 @@
+package config
+
+import (
+	"errors"
+)
+
+// stripJSONC turns JSON with comments into standard JSON. Line comments (//), block comments (/* */) and commas
+// trailing the last element of an object or a list are removed, text inside strings is kept as is. When blank is
+// true the removed characters are replaced by spaces, newlines are kept, so decoding errors report the offsets of
+// the original data. When blank is false they are dropped, leaving data that only changes when the config does.
+func stripJSONC(data []byte, blank bool) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	remove := func(removed []byte) {
+		if !blank {
+			return
+		}
+		for _, c := range removed {
+			if c == '\n' || c == '\r' {
+				out = append(out, c)
+			} else {
+				out = append(out, ' ')
+			}
+		}
+	}
+
+	for i := 0; i < len(data); {
+		switch c := data[i]; {
+		case c == '"':
+			end := stringEnd(data, i)
+			out = append(out, data[i:end]...)
+			i = end
+		case c == '/' && i+1 < len(data) && (data[i+1] == '/' || data[i+1] == '*'):
+			end, err := commentEnd(data, i)
+			if err != nil {
+				return nil, err
+			}
+			remove(data[i:end])
+			i = end
+		case c == ',' && isTrailingComma(data, i):
+			remove(data[i : i+1])
+			i++
+		default:
+			out = append(out, c)
+			i++
+		}
+	}
+	return out, nil
+}
+
+// stringEnd returns the offset following the string starting at the quote at offset start
+func stringEnd(data []byte, start int) int {
+	for i := start + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(data)
+}
+
+// commentEnd returns the offset following the comment starting at offset start. Line comments end before the
+// newline.
+func commentEnd(data []byte, start int) (int, error) {
+	if data[start+1] == '/' {
+		for i := start + 2; i < len(data); i++ {
+			if data[i] == '\n' {
+				return i, nil
+			}
+		}
+		return len(data), nil
+	}
+	for i := start + 2; i+1 < len(data); i++ {
+		if data[i] == '*' && data[i+1] == '/' {
+			return i + 2, nil
+		}
+	}
+	return 0, errors.New("unterminated block comment")
+}
+
+// isTrailingComma reports whether the comma at offset i is only followed by whitespace and comments before the end
+// of an object or a list
+func isTrailingComma(data []byte, i int) bool {
+	for i++; i < len(data); {
+		switch c := data[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '/' && i+1 < len(data) && (data[i+1] == '/' || data[i+1] == '*'):
+			end, err := commentEnd(data, i)
+			if err != nil {
+				return false
+			}
+			i = end
+		default:
+			return c == '}' || c == ']'
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStripJSONC(t *testing.T) {
+	testcases := []struct {
+		name            string
+		data            string
+		expected        string
+		expectedBlanked string
+		expectedError   string
+	}{
+		{
+			name:            "standard JSON is unchanged",
+			data:            `{"Env": "Dev", "Port": 8000, "List": [1, 2]}`,
+			expected:        `{"Env": "Dev", "Port": 8000, "List": [1, 2]}`,
+			expectedBlanked: `{"Env": "Dev", "Port": 8000, "List": [1, 2]}`,
+		},
+		{
+			name:            "line and block comments",
+			data:            "{\n  \"Env\": \"Dev\", // the environment\n  /* the\n port */ \"Port\": 8000\n}",
+			expected:        "{\n  \"Env\": \"Dev\", \n   \"Port\": 8000\n}",
+			expectedBlanked: "{\n  \"Env\": \"Dev\",                   \n        \n         \"Port\": 8000\n}",
+		},
+		{
+			name:            "trailing commas",
+			data:            "{\"List\": [1, 2, ], \"Env\": \"Dev\", // last\n}",
+			expected:        "{\"List\": [1, 2 ], \"Env\": \"Dev\" \n}",
+			expectedBlanked: "{\"List\": [1, 2  ], \"Env\": \"Dev\"         \n}",
+		},
+		{
+			name:            "comment markers and commas inside strings are kept",
+			data:            `{"Url": "https://abs.local/*path*/", "Note": "a, ]", "Quote": "\"// not a comment"}`,
+			expected:        `{"Url": "https://abs.local/*path*/", "Note": "a, ]", "Quote": "\"// not a comment"}`,
+			expectedBlanked: `{"Url": "https://abs.local/*path*/", "Note": "a, ]", "Quote": "\"// not a comment"}`,
+		},
+		{
+			name:          "unterminated block comment",
+			data:          `{"Env": "Dev" /* the environment }`,
+			expectedError: "unterminated block comment",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			stripped, err := stripJSONC([]byte(tc.data), false)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, string(stripped))
+
+			blanked, err := stripJSONC([]byte(tc.data), true)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedBlanked, string(blanked))
+			require.Len(t, blanked, len(tc.data), "blanking keeps the offsets")
+		})
+	}
+}
+
+func TestNewFromBytes_JSONC(t *testing.T) {
+	data := []byte(`{
+  // the environment for which this config applies
+  "Env": "Dev",
+  "Port": 8000, /* the port used by this API */
+  "ServiceConfigs": [
+    {"Name": "ABS", "Url": "https://abs.local",},
+  ],
+}`)
+	c, errs := NewFromBytes(data, "", WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+	require.Equal(t, "Dev", c.Env)
+	require.Equal(t, 8000, c.Port)
+	require.Equal(t, "https://abs.local", c.ServiceConfigs["ABS"].URL)
+
+	recommented, errs := NewFromBytes([]byte(`{
+  // the environment
+  "Env": "Dev",
+  "Port": 8000, /* the port */
+  "ServiceConfigs": [
+    {"Name": "ABS", "Url": "https://abs.local",},
+  ],
+}`), "", WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+	require.Equal(t, c.Hash, recommented.Hash, "comments do not change the hash")
+
+	_, errs = NewFromBytes([]byte(`{"Env": "Dev", // the environment
+  "Unknown": true}`), "")
+	require.Len(t, errs, 1)
+	require.EqualError(t, errs[0], `Error decoding config data json: unknown field "Unknown" at config data:2:3`)
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// namedListPaths are the paths of the config sections holding lists of objects
+// that are keyed by their Name when decoded, overlays merge these lists by Name
+// rather than replacing them. Paths are made of the keys leading to the list,
+// leaving out list indexes.
+var namedListPaths = []string{"ServiceConfigs", "DatabaseConfigs", "ServiceConfigs.Endpoints"}
+
+// configSource is the raw content of a single config file along with the
+// format used to decode it
+type configSource struct {
+	path   string
+	format Format
+	data   []byte
+	// expanded is set when data was rewritten by expanding includes, its offsets are no longer those of the file
+	expanded bool
+}
+
+// location names the place of offset in the source data for errors, with its line and column when the offsets
+// are those of the file, which is the case of JSON data that was not expanded
+func (src configSource) location(offset int) string {
+	name := sourceName(src.path)
+	if src.expanded || (src.format != FormatJSON && src.format != FormatAuto) {
+		return name
+	}
+	line, column := lineAndColumn(src.data, offset)
+	return fmt.Sprintf("%v:%d:%d", name, line, column)
+}
+
+// decodeTree converts a config source into a generic JSON tree of
+// map[string]interface{}, []interface{} and scalar values. Numbers are kept as
+// json.Number so they are written back out unchanged.
+func decodeTree(src configSource) (interface{}, error) {
+	jsonBytes, err := toJSON(src.data, src.format)
+	if err != nil {
+		return nil, err
+	}
+
+	var tree interface{}
+	decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+	decoder.UseNumber()
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, locateSyntaxError(src, err)
+	}
+	return tree, nil
+}
+
+// mergeTrees deep merges overlay onto base, found at path in the config, and
+// returns the result. Objects are merged key by key, matching keys case
+// insensitively as encoding/json does, lists at namedListPaths are merged
+// element by element using Name, and any other value in overlay replaces the
+// one in base.
+func mergeTrees(base interface{}, overlay interface{}, path string) interface{} {
+	baseMap, baseOK := base.(map[string]interface{})
+	overlayMap, overlayOK := overlay.(map[string]interface{})
+	if !baseOK || !overlayOK {
+		return overlay
+	}
+
+	for key, overlayValue := range overlayMap {
+		baseKey, found := findKey(baseMap, key)
+		if !found {
+			baseMap[key] = overlayValue
+			continue
+		}
+
+		keyPath := joinPath(path, key)
+		if isNamedListPath(keyPath) {
+			if merged, ok := mergeNamedLists(baseMap[baseKey], overlayValue, keyPath); ok {
+				baseMap[baseKey] = merged
+				continue
+			}
+		}
+		baseMap[baseKey] = mergeTrees(baseMap[baseKey], overlayValue, keyPath)
+	}
+	return baseMap
+}
+
+// mergeNamedLists merges the overlay list into the base list, found at path,
+// matching elements by Name, unmatched overlay elements are appended in order.
+// If either value is not a list of objects ok is false.
+func mergeNamedLists(base interface{}, overlay interface{}, path string) (merged []interface{}, ok bool) {
+	baseList, baseOK := base.([]interface{})
+	overlayList, overlayOK := overlay.([]interface{})
+	if !baseOK || !overlayOK {
+		return nil, false
+	}
+
+	merged = make([]interface{}, 0, len(baseList)+len(overlayList))
+	indexByName := make(map[string]int, len(baseList))
+	for _, item := range baseList {
+		name, isObject := elementName(item)
+		if !isObject {
+			return nil, false
+		}
+		indexByName[name] = len(merged)
+		merged = append(merged, item)
+	}
+
+	for _, item := range overlayList {
+		name, isObject := elementName(item)
+		if !isObject {
+			return nil, false
+		}
+		if i, exists := indexByName[name]; exists {
+			merged[i] = mergeTrees(merged[i], item, path)
+			continue
+		}
+		indexByName[name] = len(merged)
+		merged = append(merged, item)
+	}
+	return merged, true
+}
+
+// elementName returns the Name of a list element and whether it is an object
+func elementName(item interface{}) (string, bool) {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	key, found := findKey(m, "Name")
+	if !found {
+		return "", true
+	}
+	name, _ := m[key].(string)
+	return name, true
+}
+
+// findKey returns the key in m matching key, preferring an exact match and
+// otherwise matching case insensitively
+func findKey(m map[string]interface{}, key string) (string, bool) {
+	if _, ok := m[key]; ok {
+		return key, true
+	}
+	for k := range m {
+		if strings.EqualFold(k, key) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// isNamedListPath reports whether the list at path, made of keys as namedListPaths are, is a named list
+func isNamedListPath(path string) bool {
+	for _, p := range namedListPaths {
+		if strings.EqualFold(p, path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_mergeTrees(t *testing.T) {
+	testcases := []struct {
+		name     string
+		base     string
+		overlay  string
+		expected string
+	}{
+		{
+			name:     "nil base takes overlay",
+			base:     `null`,
+			overlay:  `{"Env": "Dev"}`,
+			expected: `{"Env": "Dev"}`,
+		},
+		{
+			name:     "scalars are replaced and new keys added",
+			base:     `{"Env": "Dev", "Port": 8000}`,
+			overlay:  `{"Port": 9000, "Options": {"A": 1}}`,
+			expected: `{"Env": "Dev", "Port": 9000, "Options": {"A": 1}}`,
+		},
+		{
+			name:     "objects are merged with case insensitive keys",
+			base:     `{"Logging": {"Level": "trace", "GrayLogURL": "10.0.1.1"}}`,
+			overlay:  `{"logging": {"level": "info"}}`,
+			expected: `{"Logging": {"Level": "info", "GrayLogURL": "10.0.1.1"}}`,
+		},
+		{
+			name:     "named lists are merged by Name",
+			base:     `{"ServiceConfigs": [{"Name": "ABS", "Url": "a", "ComponentConfigOverrides": {"Client": {"Timeout": 30, "MaxRetries": 1}}}, {"Name": "XYZ", "Url": "x"}]}`,
+			overlay:  `{"ServiceConfigs": [{"Name": "ABS", "ComponentConfigOverrides": {"Client": {"Timeout": 45}}}, {"Name": "DEF", "Url": "d"}]}`,
+			expected: `{"ServiceConfigs": [{"Name": "ABS", "Url": "a", "ComponentConfigOverrides": {"Client": {"Timeout": 45, "MaxRetries": 1}}}, {"Name": "XYZ", "Url": "x"}, {"Name": "DEF", "Url": "d"}]}`,
+		},
+		{
+			name:     "nested endpoints are merged by Name",
+			base:     `{"ServiceConfigs": [{"Name": "ABS", "Endpoints": [{"Name": "E1", "Path": "/one"}]}]}`,
+			overlay:  `{"ServiceConfigs": [{"Name": "ABS", "Endpoints": [{"Name": "E1", "Path": "/uno"}, {"Name": "E2", "Path": "/two"}]}]}`,
+			expected: `{"ServiceConfigs": [{"Name": "ABS", "Endpoints": [{"Name": "E1", "Path": "/uno"}, {"Name": "E2", "Path": "/two"}]}]}`,
+		},
+		{
+			name:     "lists named like named lists elsewhere are replaced",
+			base:     `{"Options": {"ServiceConfigs": [{"Name": "ABS", "Url": "a"}]}}`,
+			overlay:  `{"Options": {"ServiceConfigs": [{"Name": "ABS", "Timeout": 5}]}}`,
+			expected: `{"Options": {"ServiceConfigs": [{"Name": "ABS", "Timeout": 5}]}}`,
+		},
+		{
+			name:     "other lists are replaced",
+			base:     `{"Options": {"Hosts": ["a", "b"]}}`,
+			overlay:  `{"Options": {"Hosts": ["c"]}}`,
+			expected: `{"Options": {"Hosts": ["c"]}}`,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var base, overlay, expected interface{}
+			require.NoError(t, json.Unmarshal([]byte(tc.base), &base))
+			require.NoError(t, json.Unmarshal([]byte(tc.overlay), &overlay))
+			require.NoError(t, json.Unmarshal([]byte(tc.expected), &expected))
+			require.Equal(t, expected, mergeTrees(base, overlay, ""))
+		})
+	}
+}
+
+func TestDefaultConfigBuilder_ReadOverlays(t *testing.T) {
+	basePath := "testdata/example_config.yaml"
+	overlayPath := "testdata/example_config_overlay.json"
+
+	builder := options{overlayPaths: []string{overlayPath}}.builder()
+	file, err := builder.Load(basePath)
+	require.NoError(t, err)
+	defer file.Close()
+	require.NoError(t, builder.Read(file))
+
+	c := builder.GetConfig()
+	require.Equal(t, "local", c.Env)
+	require.Equal(t, 9000, c.Port)
+	require.Equal(t, "a local string", c.OptionAsString("DummyString"))
+	require.Equal(t, true, c.Options["DummyBool"])
+
+	abs, err := c.GetServiceConfig("ABS")
+	require.NoError(t, err)
+	require.Equal(t, "https://some.url.com", abs.URL)
+	require.Equal(t, "keyc_1", abs.AuthCredentials.KeyComponent1)
+	require.Equal(t, 45, abs.MergedComponentConfigs().Client.Timeout)
+	require.Equal(t, 32, abs.MergedComponentConfigs().Client.MaxConnsPerHost)
+	require.Contains(t, abs.EndPoints, "ClaimStatus")
+
+	def, err := c.GetServiceConfig("DEF")
+	require.NoError(t, err)
+	require.Equal(t, "https://def.url.com", def.URL)
+	require.Equal(t, 10, def.MergedComponentConfigs().Client.Timeout)
+
+	baseBytes, err := os.ReadFile(basePath)
+	require.NoError(t, err)
+	overlayBytes, err := os.ReadFile(overlayPath)
+	require.NoError(t, err)
+	framed := fmt.Sprintf("%d:%s%d:%s", len(baseBytes), baseBytes, len(overlayBytes), overlayBytes)
+	require.Equal(t, fmt.Sprintf("%x", md5.Sum([]byte(framed))), c.Hash)
+}
+
+func TestDefaultConfigBuilder_ReadOverlaysErrors(t *testing.T) {
+	testcases := []struct {
+		name          string
+		overlayPaths  []string
+		expectedError string
+	}{
+		{
+			name:          "missing overlay file",
+			overlayPaths:  []string{"testdata/not_a_file.json"},
+			expectedError: "Error reading overlay config file testdata/not_a_file.json",
+		},
+		{
+			name:          "overlay that is not valid json",
+			overlayPaths:  []string{"testdata/example_cabundle.pem"},
+			expectedError: "Error converting json config data testdata/example_cabundle.pem",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			builder := options{overlayPaths: tc.overlayPaths}.builder()
+			file, err := builder.Load("testdata/example_config.json")
+			require.NoError(t, err)
+			defer file.Close()
+
+			err = builder.Read(file)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.expectedError)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	cnErrors "github.com/CodeNamor/Common/errors"
+)
+
+// nameProblems returns a message for each entry of the ServiceConfigs and DatabaseConfigs lists, and of the Endpoints
+// of each service, that has no Name or the Name of an earlier entry. ServicesMap, DatabasesMap and EndpointMap keep the
+// last entry for a Name, so such entries would silently replace one another. locate returns the location of an
+// offset, empty when root was merged from several sources.
+func nameProblems(root *jsonNode, locate func(offset int) string) []string {
+	var messages []string
+	report := func(node *jsonNode, format string, args ...interface{}) {
+		message := fmt.Sprintf(format, args...)
+		if location := locate(node.offset); location != "" {
+			message += " at " + location
+		}
+		messages = append(messages, message)
+	}
+
+	// path locates the node in messages, keyPath is made of keys as namedListPaths are
+	var walk func(node *jsonNode, path string, keyPath string)
+	walk = func(node *jsonNode, path string, keyPath string) {
+		switch value := node.value.(type) {
+		case []jsonMember:
+			for _, member := range value {
+				walk(member.value, joinPath(path, member.key), joinPath(keyPath, member.key))
+			}
+		case []*jsonNode:
+			named := isNamedListPath(keyPath)
+			firstIndex := make(map[string]int, len(value))
+			for i, item := range value {
+				if name, isObject := nodeName(item); named && isObject {
+					if name == "" {
+						report(item, "%v[%d] has no Name", path, i)
+					} else if first, exists := firstIndex[name]; exists {
+						report(item, "%v[%d] has the same Name %q as %v[%d]", path, i, name, path, first)
+					} else {
+						firstIndex[name] = i
+					}
+				}
+				walk(item, path+"["+strconv.Itoa(i)+"]", keyPath)
+			}
+		}
+	}
+	walk(root, "", "")
+	return messages
+}
+
+// nodeName returns the Name of a list entry, matched as the decoder does, and whether the entry is an object
+func nodeName(node *jsonNode) (string, bool) {
+	members, isObject := node.value.([]jsonMember)
+	if !isObject {
+		return "", false
+	}
+	var name string
+	for _, member := range members {
+		value, isString := member.value.value.(string)
+		if !isString {
+			continue
+		}
+		if member.key == "Name" {
+			return value, true
+		}
+		if strings.EqualFold(member.key, "Name") && name == "" {
+			name = value
+		}
+	}
+	return name, true
+}
+
+// checkNames fails the load for entries of named lists without a Name or sharing one, jsonBytes being the JSON decoded
+// from sources. With lenientNames they are logged as warnings instead.
+func (b *defaultConfigBuilder) checkNames(sources []configSource, jsonBytes []byte) error {
+	root, err := parseJSONNodes(jsonBytes)
+	if err != nil { // the data was decoded already
+		return nil
+	}
+	locate := func(offset int) string {
+		if len(sources) != 1 {
+			return ""
+		}
+		return sources[0].location(offset)
+	}
+
+	var errs readErrors
+	for _, message := range nameProblems(root, locate) {
+		if b.lenientNames {
+			b.GetLogger().Warn(message + ", keeping the last entry with that Name")
+			continue
+		}
+		errs = append(errs, cnErrors.WithErrorAndCause(errors.New(message), "Error decoding config data"))
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"testing"
+	"testing/fstest"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+const namesTestConfig = `{
+  "Port": 8000,
+  "ServiceConfigs": [
+    {"Name": "ABS", "Url": "https://abs.local"},
+    {"Url": "https://unnamed.local"},
+    {"name": "ABS", "Url": "https://abs.remote",
+     "Endpoints": [{"Name": "ClaimStatus", "Path": "/v1"}, {"Name": "ClaimStatus", "Path": "/v2"}]}
+  ],
+  "DatabaseConfigs": [{"Name": "DB", "Server": "db.local"}, {"Name": "", "Server": "db.remote"}]
+}`
+
+func TestCheckNames(t *testing.T) {
+	testcases := []struct {
+		name           string
+		files          map[string]string
+		opts           []Option
+		expectedErrors []string
+	}{
+		{
+			name:  "entries without a name or sharing one",
+			files: map[string]string{"config.json": namesTestConfig},
+			expectedErrors: []string{
+				"Error decoding config data ServiceConfigs[1] has no Name at config.json:5:5",
+				`Error decoding config data ServiceConfigs[2] has the same Name "ABS" as ServiceConfigs[0] at config.json:6:5`,
+				`Error decoding config data ServiceConfigs[2].Endpoints[1] has the same Name "ClaimStatus" as ServiceConfigs[2].Endpoints[0] at config.json:7:60`,
+				"Error decoding config data DatabaseConfigs[1] has no Name at config.json:9:61",
+			},
+		},
+		{
+			name: "merged sources are not located",
+			files: map[string]string{
+				"config.json":  `{"Port": 8000, "DatabaseConfigs": [{"Name": "DB"}]}`,
+				"overlay.json": `{"DatabaseConfigs": [{"Server": "db.local"}]}`,
+			},
+			opts:           []Option{WithOverlays("overlay.json")},
+			expectedErrors: []string{"Error decoding config data DatabaseConfigs[1] has no Name"},
+		},
+		{
+			name: "names that only differ by case are distinct",
+			files: map[string]string{
+				"config.json": `{"Port": 8000, "ServiceConfigs": [{"Name": "ABS", "Url": "https://abs.local"}, {"Name": "abs", "Url": "https://abs.remote"}]}`,
+			},
+		},
+		{
+			name: "lists elsewhere named like named lists are not checked",
+			files: map[string]string{
+				"config.json": `{"Port": 8000, "Options": {
+  "Endpoints": [{"Path": "/health"}, {"Path": "/ready"}],
+  "ServiceConfigs": [{"Name": "A"}, {"Name": "A"}]
+}}`,
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for name, data := range tc.files {
+				fsys[name] = &fstest.MapFile{Data: []byte(data)}
+			}
+
+			c, errs := NewFromFS(fsys, "config.json", append([]Option{WithRetryClientBuilder(httpClientBuilder)}, tc.opts...)...)
+			if tc.expectedErrors != nil {
+				require.Nil(t, c)
+				require.Equal(t, tc.expectedErrors, errorStrings(errs))
+				return
+			}
+			require.Empty(t, errs)
+		})
+	}
+}
+
+func TestWithLenientNames(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	c, errs := NewFromBytes([]byte(namesTestConfig), "",
+		WithLenientNames(), WithLogger(logger), WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+
+	require.Equal(t, "https://abs.remote", c.ServiceConfigs["ABS"].URL, "the last entry wins")
+	require.Equal(t, "/v2", c.ServiceConfigs["ABS"].EndPoints["ClaimStatus"].Path)
+	require.Equal(t, "https://unnamed.local", c.ServiceConfigs[""].URL)
+	require.Equal(t, "db.remote", c.DatabaseConfigs[""].Server)
+
+	var warnings []string
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == log.WarnLevel {
+			warnings = append(warnings, entry.Message)
+		}
+	}
+	require.Equal(t, []string{
+		"ServiceConfigs[1] has no Name at config data:5:5, keeping the last entry with that Name",
+		`ServiceConfigs[2] has the same Name "ABS" as ServiceConfigs[0] at config data:6:5, keeping the last entry with that Name`,
+		`ServiceConfigs[2].Endpoints[1] has the same Name "ClaimStatus" as ServiceConfigs[2].Endpoints[0] at config data:7:60, keeping the last entry with that Name`,
+		"DatabaseConfigs[1] has no Name at config data:9:61, keeping the last entry with that Name",
+	}, warnings)
+}
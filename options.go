@@ -0,0 +1,205 @@
+package config
+
+import (
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/CodeNamor/http/apiclient"
+	log "github.com/sirupsen/logrus"
+)
+
+// Option customizes how New loads and builds a Config
+type Option func(*options)
+
+type options struct {
+	format       Format
+	overlayPaths []string
+	envPrefix    string
+	flags        *Flags
+	fsys         fs.FS
+	baseDir      string
+
+	retryClientBuilder RetryClientBuilderFn
+	authKeyGetterFn    NewAuthKeyGetterFn
+	transport          *http.Transport
+	logger             *log.Logger
+	authKeyWorkers     int
+	authKeyTimeout     time.Duration
+	interpolation      bool
+	validation         bool
+	schemaValidation   bool
+	lenientNames       bool
+	// customAuthKeys is set when the auth keys come from a getter given by WithAuthKeyGetter or WithAuthKeyGetterFn
+	customAuthKeys bool
+}
+
+// WithFormat decodes the config file using format rather than selecting the
+// format from the config file extension
+func WithFormat(format Format) Option {
+	return func(o *options) {
+		o.format = format
+	}
+}
+
+// WithOverlays deep merges the given config files, in order, over the config
+// file before it is decoded. ServiceConfigs, DatabaseConfigs and Endpoints are
+// merged by Name so an overlay only needs to list the values it changes.
+func WithOverlays(overlayPaths ...string) Option {
+	return func(o *options) {
+		o.overlayPaths = append(o.overlayPaths, overlayPaths...)
+	}
+}
+
+// WithEnvPrefix overrides config values with environment variables named prefix_PATH where PATH is the path to the
+// value with segments separated by a double underscore, e.g. APP_SERVICECONFIGS__ABS__URL. Overrides are applied
+// before service component configs are merged with the defaults.
+func WithEnvPrefix(prefix string) Option {
+	return func(o *options) {
+		o.envPrefix = prefix
+	}
+}
+
+// WithFlags applies the command-line overrides collected by the Flags returned from BindFlags, taking precedence
+// over the config file, overlays and environment overrides
+func WithFlags(flags *Flags) Option {
+	return func(o *options) {
+		o.flags = flags
+	}
+}
+
+// WithInterpolation resolves ${NAME}, ${NAME:-fallback} and ${ref:Path.To.Value} expressions in the string values
+// of the config, with $${ standing for a literal ${. Values set by environment and flag overrides are interpolated
+// too, and references see the overridden values.
+func WithInterpolation() Option {
+	return func(o *options) {
+		o.interpolation = true
+	}
+}
+
+// WithAuthKeyGetter uses getter to retrieve the auth keys of services that require auth
+// instead of reading them from each service AuthEnvironmentVariable. A nil getter is ignored.
+func WithAuthKeyGetter(getter AuthKeyGetter) Option {
+	if getter == nil {
+		return func(*options) {}
+	}
+	return WithAuthKeyGetterFn(func(AuthServiceConfig) AuthKeyGetter {
+		return getter
+	})
+}
+
+// WithAuthKeyGetterFn uses fn to create the AuthKeyGetter from the AuthServiceConfig read from the config file.
+// A nil fn is ignored.
+func WithAuthKeyGetterFn(fn NewAuthKeyGetterFn) Option {
+	return func(o *options) {
+		if fn != nil {
+			o.authKeyGetterFn = fn
+			o.customAuthKeys = true
+		}
+	}
+}
+
+// WithRetryClientBuilder uses fn to wrap each http client built from the config, replacing
+// apiclient.NewExtendedHTTPClient. A nil fn is ignored.
+func WithRetryClientBuilder(fn RetryClientBuilderFn) Option {
+	return func(o *options) {
+		if fn != nil {
+			o.retryClientBuilder = fn
+		}
+	}
+}
+
+// WithHTTPTransport clones transport as the base of every http client built from the config. The
+// client config settings (TLS, idle connections, connections per host and compression) are applied
+// on top, any other setting such as Proxy or DialContext is kept.
+func WithHTTPTransport(transport *http.Transport) Option {
+	return func(o *options) {
+		o.transport = transport
+	}
+}
+
+// WithLogger sends the logging done while loading the config to logger rather than the logrus
+// standard logger
+func WithLogger(logger *log.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithAuthKeyConcurrency fetches up to n service auth keys at a time rather than one at a time. The AuthKeyGetter
+// must be safe for concurrent use when n is greater than 1.
+func WithAuthKeyConcurrency(n int) Option {
+	return func(o *options) {
+		o.authKeyWorkers = n
+	}
+}
+
+// WithAuthKeyTimeout gives up on fetching the auth key of a service after timeout, failing the load for that
+// service. AuthKeyGetters that are not a ContextAuthKeyGetter are abandoned rather than interrupted: their call
+// keeps running in its own goroutine until it returns, possibly alongside the calls for other services, and its
+// result is discarded.
+func WithAuthKeyTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.authKeyTimeout = timeout
+	}
+}
+
+// WithValidation checks the loaded config against the rules of validateConfig, such as Port being a port number and
+// service URLs being absolute, failing the load with every violation found. Services requiring auth are not checked
+// for a source of their key when a getter is given with WithAuthKeyGetter or WithAuthKeyGetterFn.
+func WithValidation() Option {
+	return func(o *options) {
+		o.validation = true
+	}
+}
+
+// WithSchemaValidation checks the config files, with their includes expanded, against the schema returned by JSONSchema
+// before decoding them, reporting every violation with its location and JSON pointer rather than stopping at the first
+// decoding error
+func WithSchemaValidation() Option {
+	return func(o *options) {
+		o.schemaValidation = true
+	}
+}
+
+// WithLenientNames keeps the last of the ServiceConfigs, DatabaseConfigs or Endpoints entries sharing a Name, or
+// having none, logging a warning for each replaced entry rather than failing the load
+func WithLenientNames() Option {
+	return func(o *options) {
+		o.lenientNames = true
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		retryClientBuilder: apiclient.NewExtendedHTTPClient,
+		authKeyGetterFn:    newAdapterService,
+		logger:             log.StandardLogger(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// builder creates the configBuilder described by the options
+func (o options) builder() *defaultConfigBuilder {
+	return &defaultConfigBuilder{
+		format:         o.format,
+		overlayPaths:   o.overlayPaths,
+		envPrefix:      o.envPrefix,
+		flags:          o.flags,
+		interpolation:  o.interpolation,
+		fsys:           o.fsys,
+		baseDir:        o.baseDir,
+		transport:      o.transport,
+		logger:         o.logger,
+		authKeyWorkers: o.authKeyWorkers,
+		authKeyTimeout: o.authKeyTimeout,
+
+		validation:       o.validation,
+		customAuthKeys:   o.customAuthKeys,
+		schemaValidation: o.schemaValidation,
+		lenientNames:     o.lenientNames,
+	}
+}
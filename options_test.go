@@ -0,0 +1,188 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/CodeNamor/http/apiclient"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+const optionsTestConfig = `{
+  "DefaultComponentConfigs": {
+    "Client": {
+      "Timeout": 10,
+      "MaxConnsPerHost": 32
+    }
+  },
+  "ServiceConfigs": [
+    {
+      "Name": "ABS",
+      "Url": "https://some.url.com",
+      "AuthRequired": true
+    }
+  ]
+}`
+
+type mockKeyGetter struct {
+	keys map[string]string
+	err  error
+}
+
+func (m mockKeyGetter) GetServiceKey(service *ServiceConfig, client apiclient.RetryClient) (string, error) {
+	return m.keys[service.Name], m.err
+}
+
+// httpClientBuilder returns the *http.Client unwrapped so tests can inspect it
+func httpClientBuilder(maxRetries int, client *http.Client) apiclient.RetryClient {
+	return client
+}
+
+func TestWithAuthKeyGetter(t *testing.T) {
+	testcases := []struct {
+		name          string
+		getter        AuthKeyGetter
+		expectedKey   string
+		expectedError string
+	}{
+		{
+			name:        "keys come from the getter",
+			getter:      mockKeyGetter{keys: map[string]string{"ABS": "123"}},
+			expectedKey: "123",
+		},
+		{
+			name:          "getter errors are returned",
+			getter:        mockKeyGetter{err: errors.New("This is a mock error")},
+			expectedError: "Error retrieving auth key for ABS: This is a mock error",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, errs := NewFromBytes([]byte(optionsTestConfig), "", WithAuthKeyGetter(tc.getter))
+			if tc.expectedError != "" {
+				require.Nil(t, c)
+				require.Len(t, errs, 1)
+				require.Contains(t, errs[0].Error(), tc.expectedError)
+				return
+			}
+			require.Empty(t, errs)
+			require.Equal(t, tc.expectedKey, c.ServiceConfigs["ABS"].AuthKey)
+		})
+	}
+}
+
+func TestWithAuthKeyGetterFn(t *testing.T) {
+	var received AuthServiceConfig
+	fn := func(authConfig AuthServiceConfig) AuthKeyGetter {
+		received = authConfig
+		return mockKeyGetter{keys: map[string]string{"ABS": "123"}}
+	}
+
+	data := `{"AuthServiceConfig": {"Url": "http://auth.local", "Uid": "uid", "Pwd": "pwd"}}`
+	_, errs := NewFromBytes([]byte(data), "", WithAuthKeyGetterFn(fn))
+	require.Empty(t, errs)
+	require.Equal(t, AuthServiceConfig{Url: "http://auth.local", Uid: "uid", Pwd: "pwd"}, received)
+}
+
+func TestNewWithContext(t *testing.T) {
+	path := t.TempDir() + "/config.json"
+	require.NoError(t, os.WriteFile(path, []byte(optionsTestConfig), 0o600))
+
+	t.Run("keys are loaded", func(t *testing.T) {
+		c, errs := NewWithContext(context.Background(), path,
+			WithAuthKeyGetter(mockKeyGetter{keys: map[string]string{"ABS": "123"}}),
+			WithAuthKeyConcurrency(2))
+		require.Empty(t, errs)
+		require.Equal(t, "123", c.ServiceConfigs["ABS"].AuthKey)
+	})
+
+	t.Run("cancelled context fails the key", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		c, errs := NewWithContext(ctx, path, WithAuthKeyGetter(mockKeyGetter{keys: map[string]string{"ABS": "123"}}))
+		require.Nil(t, c)
+		require.Len(t, errs, 1)
+		require.EqualError(t, errs[0], "Error retrieving auth key for ABS: context canceled")
+	})
+
+	t.Run("slow key times out", func(t *testing.T) {
+		getter := &blockingKeyGetter{release: make(chan struct{})}
+		defer close(getter.release)
+		c, errs := NewWithContext(context.Background(), path, WithAuthKeyGetter(getter), WithAuthKeyTimeout(10*time.Millisecond))
+		require.Nil(t, c)
+		require.Len(t, errs, 1)
+		require.EqualError(t, errs[0], "Error retrieving auth key for ABS: context deadline exceeded")
+	})
+}
+
+func TestWithRetryClientBuilder(t *testing.T) {
+	retries := []int{}
+	builder := func(maxRetries int, client *http.Client) apiclient.RetryClient {
+		retries = append(retries, maxRetries)
+		return client
+	}
+
+	data := `{"DefaultComponentConfigs": {"Client": {"MaxRetries": 2}}, "ServiceConfigs": [{"Name": "ABS", "ComponentConfigOverrides": {"Client": {"MaxRetries": 5}}}]}`
+	c, errs := NewFromBytes([]byte(data), "", WithRetryClientBuilder(builder))
+	require.Empty(t, errs)
+	require.Equal(t, []int{2, 5}, retries)
+	require.IsType(t, &http.Client{}, c.ServiceConfigs["ABS"].HTTPClient)
+}
+
+func TestWithHTTPTransport(t *testing.T) {
+	proxyURL, err := url.Parse("http://proxy.local:3128")
+	require.NoError(t, err)
+	base := &http.Transport{
+		Proxy:        http.ProxyURL(proxyURL),
+		MaxIdleConns: 7,
+	}
+
+	c, errs := NewFromBytes([]byte(optionsTestConfig), "",
+		WithHTTPTransport(base),
+		WithRetryClientBuilder(httpClientBuilder),
+		WithAuthKeyGetter(mockKeyGetter{keys: map[string]string{"ABS": "123"}}),
+	)
+	require.Empty(t, errs)
+
+	transport := c.ServiceConfigs["ABS"].HTTPClient.(*http.Client).Transport.(*http.Transport)
+	require.NotSame(t, base, transport)
+	require.Equal(t, 7, transport.MaxIdleConns)
+	require.Equal(t, 32, transport.MaxConnsPerHost)
+	require.NotNil(t, transport.TLSClientConfig)
+	proxy, err := transport.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "some.url.com"}})
+	require.NoError(t, err)
+	require.Equal(t, proxyURL, proxy)
+
+	// the config settings are not written to the base transport
+	require.Equal(t, 0, base.MaxConnsPerHost)
+}
+
+func TestWithLogger(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(log.TraceLevel)
+
+	_, errs := NewFromBytes([]byte(`{}`), "", WithLogger(logger))
+	require.Empty(t, errs)
+
+	messages := []string{}
+	for _, entry := range hook.AllEntries() {
+		messages = append(messages, entry.Message)
+	}
+	require.Contains(t, messages, "Reading config data")
+	require.Contains(t, messages, "Loading auth keys")
+}
+
+func TestNilOptionsAreIgnored(t *testing.T) {
+	data := `{"ServiceConfigs": [{"Name": "ABS"}]}`
+	c, errs := NewFromBytes([]byte(data), "",
+		WithAuthKeyGetter(nil), WithAuthKeyGetterFn(nil), WithRetryClientBuilder(nil))
+	require.Empty(t, errs)
+	require.NotNil(t, c.ServiceConfigs["ABS"].HTTPClient)
+}
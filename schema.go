@@ -0,0 +1,188 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// schemaDialect is the JSON Schema draft the generated schema follows
+const schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// schemaConstraints are the keywords added to the schema of a field, keyed by type and field name, mirroring the rules
+// of validateConfig that a schema can express
+var schemaConstraints = map[string]map[string]interface{}{
+	"Config.Port":                      {"minimum": 1, "maximum": 65535},
+	"ClientConfig.Timeout":             {"minimum": 0},
+	"ClientConfig.IdleConnTimeout":     {"minimum": 0},
+	"ClientConfig.MaxIdleConnsPerHost": {"minimum": 0},
+	"ClientConfig.MaxConnsPerHost":     {"minimum": 0},
+	"ClientConfig.MaxRetries":          {"minimum": 0},
+}
+
+// schemaSkippedFields are the fields, keyed by type and field name, that are computed by the loader rather than read
+// from config files
+var schemaSkippedFields = map[string]bool{
+	"Config.Hash": true,
+}
+
+// namedListTypes are the maps decoded from a list of objects keyed by their Name
+var namedListTypes = map[reflect.Type]bool{
+	reflect.TypeOf(ServicesMap{}):  true,
+	reflect.TypeOf(DatabasesMap{}): true,
+	reflect.TypeOf(EndpointMap{}):  true,
+}
+
+// schemaGenerator builds the schema of a type, collecting the schemas of the structs it refers to
+type schemaGenerator struct {
+	defs map[string]interface{}
+	// strict holds the structs found outside of named lists, whose unknown fields are rejected
+	strict map[reflect.Type]bool
+	// lenient is set while generating the entries of named lists, which are decoded ignoring unknown fields
+	lenient bool
+}
+
+// JSONSchema returns the JSON Schema (draft 2020-12) of config files, for editor autocompletion and for validating
+// config files in CI. ServiceConfigs, DatabaseConfigs and EndPoints are lists of objects with a Name, which may hold
+// $include directives, and flags such as DisableCompression are 0 (unset), 1 (false), 2 (true) or a boolean. Fields
+// are named as in the Go types. As New does, unknown fields are rejected except within the entries of these lists,
+// whose structs are described by their own definitions, suffixed with InList when the struct is also found elsewhere.
+func JSONSchema() ([]byte, error) {
+	return json.MarshalIndent(configSchema(), "", "  ")
+}
+
+// configSchema returns the schema of the Config type as a JSON tree
+func configSchema() map[string]interface{} {
+	g := &schemaGenerator{defs: make(map[string]interface{}), strict: make(map[reflect.Type]bool)}
+	strictStructs(reflect.TypeOf(Config{}), g.strict)
+	root := g.structSchema(reflect.TypeOf(Config{}))
+	root["$schema"] = schemaDialect
+	root["title"] = "Config"
+
+	g.defs["configFlag"] = map[string]interface{}{
+		"description": "0 unset, 1 false, 2 true, or a boolean",
+		"anyOf": []interface{}{
+			map[string]interface{}{"type": "integer", "enum": []interface{}{int(UnSet), int(False), int(True)}},
+			map[string]interface{}{"type": "boolean"},
+		},
+	}
+	g.defs["include"] = map[string]interface{}{
+		"description": "replaced by the objects held in the files matching the glob patterns",
+		"type":        "object",
+		"properties": map[string]interface{}{
+			includeKey: map[string]interface{}{
+				"anyOf": []interface{}{
+					map[string]interface{}{"type": "string"},
+					map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				},
+			},
+		},
+		"required":             []interface{}{includeKey},
+		"additionalProperties": false,
+	}
+	root["$defs"] = g.defs
+	return root
+}
+
+// schema returns the schema of t, a reference for structs
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	if namedListTypes[t] {
+		lenient := g.lenient
+		g.lenient = true
+		entry := g.schema(t.Elem().Elem())
+		g.lenient = lenient
+		return map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"anyOf": []interface{}{entry, schemaRef("include")},
+			},
+		}
+	}
+	if t == reflect.TypeOf(configFlag(0)) {
+		return schemaRef("configFlag")
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schema(t.Elem())
+	case reflect.Struct:
+		name := t.Name()
+		if g.lenient && g.strict[t] {
+			name += "InList"
+		}
+		if _, exists := g.defs[name]; !exists {
+			g.defs[name] = g.structSchema(t)
+		}
+		return schemaRef(name)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	default: // interface{} holds any value
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema returns the schema of the fields of t that are read from config files
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+		name := fieldJSONName(field)
+		if name == "-" || schemaSkippedFields[t.Name()+"."+field.Name] {
+			continue
+		}
+
+		property := g.schema(field.Type)
+		for keyword, value := range schemaConstraints[t.Name()+"."+field.Name] {
+			property[keyword] = value
+		}
+		properties[name] = property
+	}
+
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": g.lenient,
+	}
+	if _, named := properties["Name"]; named { // the elements of named lists
+		schema["required"] = []interface{}{"Name"}
+	}
+	return schema
+}
+
+// strictStructs adds to found the structs reachable from t without going through a named list
+func strictStructs(t reflect.Type, found map[reflect.Type]bool) {
+	if namedListTypes[t] {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		strictStructs(t.Elem(), found)
+	case reflect.Struct:
+		if found[t] {
+			return
+		}
+		found[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			if field := t.Field(i); field.PkgPath == "" && fieldJSONName(field) != "-" {
+				strictStructs(field.Type, found)
+			}
+		}
+	}
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/$defs/" + name}
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONSchema(t *testing.T) {
+	data, err := JSONSchema()
+	require.NoError(t, err)
+
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &schema))
+	require.Equal(t, "https://json-schema.org/draft/2020-12/schema", schema["$schema"])
+	require.Equal(t, false, schema["additionalProperties"], "unknown fields are rejected")
+
+	defs := schema["$defs"].(map[string]interface{})
+	for _, name := range []string{"ComponentConfigs", "ClientConfig", "ServiceConfig", "DatabaseConfig", "EndpointConfig"} {
+		require.Contains(t, defs, name)
+	}
+
+	properties := schema["properties"].(map[string]interface{})
+	require.NotContains(t, properties, "DefaultHTTPClient", "fields not read from config files are left out")
+	require.NotContains(t, properties, "Hash", "fields computed by the loader are left out")
+	require.Equal(t, map[string]interface{}{"type": "integer", "minimum": 1.0, "maximum": 65535.0}, properties["Port"])
+	require.Equal(t, map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"anyOf": []interface{}{
+				map[string]interface{}{"$ref": "#/$defs/ServiceConfig"},
+				map[string]interface{}{"$ref": "#/$defs/include"},
+			},
+		},
+	}, properties["ServiceConfigs"], "named maps are lists of objects or include directives")
+
+	service := defs["ServiceConfig"].(map[string]interface{})
+	require.Equal(t, []interface{}{"Name"}, service["required"])
+	require.Equal(t, true, service["additionalProperties"], "unknown fields of list entries are ignored")
+	serviceProperties := service["properties"].(map[string]interface{})
+	require.Contains(t, serviceProperties, "Url", "fields are named by their json tag")
+	require.NotContains(t, serviceProperties, "HTTPClient")
+	require.Equal(t, map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"anyOf": []interface{}{
+				map[string]interface{}{"$ref": "#/$defs/EndpointConfig"},
+				map[string]interface{}{"$ref": "#/$defs/include"},
+			},
+		},
+	}, serviceProperties["EndPoints"])
+
+	require.Equal(t, map[string]interface{}{"$ref": "#/$defs/ComponentConfigsInList"}, serviceProperties["ComponentConfigOverrides"],
+		"structs found in list entries and elsewhere have a lenient definition for the entries")
+	require.Equal(t, false, defs["ClientConfig"].(map[string]interface{})["additionalProperties"])
+	require.Equal(t, true, defs["ClientConfigInList"].(map[string]interface{})["additionalProperties"])
+
+	client := defs["ClientConfig"].(map[string]interface{})["properties"].(map[string]interface{})
+	require.Equal(t, map[string]interface{}{"$ref": "#/$defs/configFlag"}, client["DisableCompression"])
+	require.Equal(t, map[string]interface{}{
+		"description": "0 unset, 1 false, 2 true, or a boolean",
+		"anyOf": []interface{}{
+			map[string]interface{}{"type": "integer", "enum": []interface{}{0.0, 1.0, 2.0}},
+			map[string]interface{}{"type": "boolean"},
+		},
+	}, defs["configFlag"])
+
+	require.Equal(t, map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{}}, properties["Options"])
+}
@@ -0,0 +1,314 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	cnErrors "github.com/CodeNamor/Common/errors"
+)
+
+// jsonNode is a decoded JSON value along with the offset it starts at
+type jsonNode struct {
+	offset int
+	// value is a string, json.Number, bool, nil, []jsonMember for objects or []*jsonNode for lists
+	value interface{}
+}
+
+// jsonMember is a member of a JSON object, in the order found in the data
+type jsonMember struct {
+	key       string
+	keyOffset int
+	value     *jsonNode
+}
+
+// nodeParser decodes JSON data into jsonNodes
+type nodeParser struct {
+	data    []byte
+	decoder *json.Decoder
+}
+
+// parseJSONNodes decodes data keeping the offset of every value
+func parseJSONNodes(data []byte) (*jsonNode, error) {
+	p := &nodeParser{data: data, decoder: json.NewDecoder(bytes.NewReader(data))}
+	p.decoder.UseNumber()
+	node, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.decoder.Token(); err != io.EOF {
+		return nil, errors.New("invalid data after the top-level value")
+	}
+	return node, nil
+}
+
+func (p *nodeParser) parse() (*jsonNode, error) {
+	offset := p.nextOffset()
+	token, err := p.decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		var members []jsonMember
+		for p.decoder.More() {
+			keyOffset := p.nextOffset()
+			key, err := p.decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			members = append(members, jsonMember{key: key.(string), keyOffset: keyOffset, value: value})
+		}
+		_, err = p.decoder.Token()
+		return &jsonNode{offset: offset, value: members}, err
+	case json.Delim('['):
+		items := []*jsonNode{}
+		for p.decoder.More() {
+			item, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		_, err = p.decoder.Token()
+		return &jsonNode{offset: offset, value: items}, err
+	default:
+		return &jsonNode{offset: offset, value: token}, nil
+	}
+}
+
+// nextOffset returns the offset of the next token, skipping the whitespace and separators the decoder has yet to read
+func (p *nodeParser) nextOffset() int {
+	offset := int(p.decoder.InputOffset())
+	for offset < len(p.data) && strings.IndexByte(" \t\r\n,:", p.data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// schemaValidator checks a source against the config schema
+type schemaValidator struct {
+	defs map[string]interface{}
+	src  configSource
+	errs []error
+}
+
+// validateSchema checks every source against the schema returned by JSONSchema and returns all the violations
+// found, each with the location of the value at fault and its JSON pointer
+func validateSchema(sources []configSource) []error {
+	schema := configSchema()
+	var errs []error
+	for _, src := range sources {
+		errs = append(errs, validateSourceSchema(schema, src)...)
+	}
+	return errs
+}
+
+func validateSourceSchema(schema map[string]interface{}, src configSource) []error {
+	name := sourceName(src.path)
+	jsonBytes, err := toJSON(src.data, src.format)
+	if err != nil {
+		return []error{cnErrors.WithErrorAndCause(err, "Error converting "+src.format.String()+" config data "+name)}
+	}
+	root, err := parseJSONNodes(jsonBytes)
+	if err != nil {
+		return []error{cnErrors.WithErrorAndCause(locateSyntaxError(src, err), "Error parsing config data "+name)}
+	}
+
+	v := &schemaValidator{defs: schema["$defs"].(map[string]interface{}), src: src}
+	v.validate(root, schema, "")
+	return v.errs
+}
+
+// report records a violation of the value at pointer
+func (v *schemaValidator) report(node *jsonNode, pointer string, format string, args ...interface{}) {
+	location := v.src.location(node.offset)
+	if pointer != "" {
+		location += " " + pointer
+	}
+	v.errs = append(v.errs, &cnErrors.ErrorLog{
+		RootCause: "Config does not match the schema at " + location + ":",
+		Err:       fmt.Errorf(format, args...),
+	})
+}
+
+// validate checks node against schema, using the keywords found in the generated schema. Like decoding, null is
+// accepted for any value and leaves it unset.
+func (v *schemaValidator) validate(node *jsonNode, schema map[string]interface{}, pointer string) {
+	if node.value == nil {
+		return
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		v.validate(node, v.defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]interface{}), pointer)
+		return
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		v.validateAnyOf(node, anyOf, pointer)
+		return
+	}
+
+	if expected, ok := schema["type"].(string); ok {
+		if actual := jsonType(node); actual != expected && !(expected == "number" && actual == "integer") {
+			v.report(node, pointer, "expected %v, got %v", expected, actual)
+			return
+		}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && !inEnum(node, enum) {
+		v.report(node, pointer, "%v is not one of %v", node.value, joinValues(enum))
+	}
+	if number, ok := node.value.(json.Number); ok {
+		value, _ := number.Float64()
+		if minimum, ok := schema["minimum"]; ok && value < toFloat(minimum) {
+			v.report(node, pointer, "%v is less than the minimum %v", number, minimum)
+		}
+		if maximum, ok := schema["maximum"]; ok && value > toFloat(maximum) {
+			v.report(node, pointer, "%v is greater than the maximum %v", number, maximum)
+		}
+	}
+
+	switch value := node.value.(type) {
+	case []jsonMember:
+		v.validateObject(node, value, schema, pointer)
+	case []*jsonNode:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range value {
+				v.validate(item, items, pointer+"/"+strconv.Itoa(i))
+			}
+		}
+	}
+}
+
+// validateObject checks the members of an object against properties, additionalProperties and required. Members
+// are matched to properties as decoding matches them to fields, preferring an exact match but otherwise ignoring case.
+func (v *schemaValidator) validateObject(node *jsonNode, members []jsonMember, schema map[string]interface{}, pointer string) {
+	properties, _ := schema["properties"].(map[string]interface{})
+	present := make(map[string]bool, len(members))
+	for _, member := range members {
+		memberPointer := pointer + "/" + escapePointer(member.key)
+		if name, ok := propertyName(properties, member.key); ok {
+			present[name] = true
+			v.validate(member.value, properties[name].(map[string]interface{}), memberPointer)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.report(member.value, memberPointer, "unknown field %q", member.key)
+			}
+		case map[string]interface{}:
+			v.validate(member.value, additional, memberPointer)
+		}
+	}
+
+	required, _ := schema["required"].([]interface{})
+	for _, name := range required {
+		if !present[name.(string)] {
+			v.report(node, pointer, "missing required field %q", name)
+		}
+	}
+}
+
+// propertyName returns the name of the property key refers to: the property named key, or else the first one, in
+// lexical order, whose name matches key ignoring case
+func propertyName(properties map[string]interface{}, key string) (string, bool) {
+	if _, ok := properties[key]; ok {
+		return key, true
+	}
+	folded := ""
+	for name := range properties {
+		if strings.EqualFold(name, key) && (folded == "" || name < folded) {
+			folded = name
+		}
+	}
+	return folded, folded != ""
+}
+
+// validateAnyOf checks node against each schema and reports the violations of the closest one when none matches
+func (v *schemaValidator) validateAnyOf(node *jsonNode, anyOf []interface{}, pointer string) {
+	var closest []error
+	for i, schema := range anyOf {
+		branch := &schemaValidator{defs: v.defs, src: v.src}
+		branch.validate(node, schema.(map[string]interface{}), pointer)
+		if len(branch.errs) == 0 {
+			return
+		}
+		if i == 0 || len(branch.errs) < len(closest) {
+			closest = branch.errs
+		}
+	}
+	v.errs = append(v.errs, closest...)
+}
+
+// jsonType returns the JSON Schema type of node, numbers without a fraction or exponent being integers
+func jsonType(node *jsonNode) string {
+	switch value := node.value.(type) {
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := value.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case bool:
+		return "boolean"
+	case []jsonMember:
+		return "object"
+	case []*jsonNode:
+		return "array"
+	default:
+		return "null"
+	}
+}
+
+func inEnum(node *jsonNode, enum []interface{}) bool {
+	for _, allowed := range enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(node.value) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinValues(values []interface{}) string {
+	s := make([]string, len(values))
+	for i, value := range values {
+		s[i] = fmt.Sprint(value)
+	}
+	return strings.Join(s, ", ")
+}
+
+func toFloat(value interface{}) float64 {
+	switch n := value.(type) {
+	case int:
+		return float64(n)
+	case float64:
+		return n
+	default:
+		return 0
+	}
+}
+
+// escapePointer escapes a key as a JSON pointer reference token
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// lineAndColumn returns the 1-based line and column of offset in data
+func lineAndColumn(data []byte, offset int) (line int, column int) {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = offset - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
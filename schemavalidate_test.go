@@ -0,0 +1,151 @@
+package config
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithSchemaValidation(t *testing.T) {
+	testcases := []struct {
+		name           string
+		files          map[string]string
+		configPath     string
+		opts           []Option
+		expectedErrors []string
+	}{
+		{
+			name:       "valid config with comments and includes",
+			configPath: "config.json",
+			files: map[string]string{
+				"config.json": `{
+  // the port used by this API
+  "Port": 8000,
+  "DefaultComponentConfigs": {"Client": {"Timeout": 10, "DisableCompression": true, "InsecureSkipVerify": 1}},
+  "ServiceConfigs": [
+    {"$include": "services/*.json"},
+    {"Name": "ABS", "Url": "https://abs.local", "EndPoints": [{"Name": "ClaimStatus", "Path": "/claims"}]},
+  ],
+  "Options": {"anything": ["goes", 1]}
+}`,
+				"services/claims.json": `{"Name": "CLAIMS", "Url": "https://claims.local"}`,
+			},
+		},
+		{
+			name:       "keys in another case and null values",
+			configPath: "config.json",
+			files: map[string]string{
+				"config.json": `{
+  "port": 8000,
+  "Env": null,
+  "defaultComponentConfigs": {"client": {"timeout": 10, "CABundlePath": null}},
+  "serviceConfigs": [{"name": "ABS", "url": "https://abs.local", "endpoints": [{"NAME": "ClaimStatus"}]}]
+}`,
+			},
+		},
+		{
+			name:       "unknown fields of list entries are ignored as when decoding",
+			configPath: "config.json",
+			files: map[string]string{
+				"config.json": `{"Port": 8000, "ServiceConfigs": [{"Name": "ABS", "Retries": 3, "ComponentConfigOverrides": {"Client": {"Tmeout": 5}}}]}`,
+			},
+		},
+		{
+			name:       "violations in included files",
+			configPath: "config.json",
+			files: map[string]string{
+				"config.json":          `{"Port": 8000, "ServiceConfigs": [{"$include": "services/*.json"}]}`,
+				"services/claims.json": `{"Name": "CLAIMS", "Url": 5, "ComponentConfigOverrides": {"Client": {"Timeout": -1}}}`,
+			},
+			expectedErrors: []string{
+				"Config does not match the schema at config.json /ServiceConfigs/0/ComponentConfigOverrides/Client/Timeout: -1 is less than the minimum 0",
+				"Config does not match the schema at config.json /ServiceConfigs/0/Url: expected string, got integer",
+			},
+		},
+		{
+			name:       "every violation with its location",
+			configPath: "config.json",
+			files: map[string]string{
+				"config.json": `{
+  "Port": "8000",
+  "Logging": {"Levl": "info"},
+  "DefaultComponentConfigs": {"Client": {"Timeout": -1, "MaxRetries": 1.5, "DisableCompression": 3}},
+  "ServiceConfigs": [
+    {"Url": "https://abs.local"},
+    {"Name": "CLAIMS", "EndPoints": {"Name": "ClaimStatus"}}
+  ]
+}`,
+			},
+			expectedErrors: []string{
+				"Config does not match the schema at config.json:2:11 /Port: expected integer, got string",
+				`Config does not match the schema at config.json:3:23 /Logging/Levl: unknown field "Levl"`,
+				"Config does not match the schema at config.json:4:53 /DefaultComponentConfigs/Client/Timeout: -1 is less than the minimum 0",
+				"Config does not match the schema at config.json:4:71 /DefaultComponentConfigs/Client/MaxRetries: expected integer, got number",
+				"Config does not match the schema at config.json:4:98 /DefaultComponentConfigs/Client/DisableCompression: 3 is not one of 0, 1, 2",
+				`Config does not match the schema at config.json:6:5 /ServiceConfigs/0: missing required field "Name"`,
+				"Config does not match the schema at config.json:7:37 /ServiceConfigs/1/EndPoints: expected array, got object",
+			},
+		},
+		{
+			name:       "overlays and other formats",
+			configPath: "config.yaml",
+			opts:       []Option{WithOverlays("overlay.json")},
+			files: map[string]string{
+				"config.yaml":  "Port: 8000\nEnv: [dev]\n",
+				"overlay.json": "{\n  \"Port\": 0\n}",
+			},
+			expectedErrors: []string{
+				"Config does not match the schema at config.yaml /Env: expected string, got array",
+				"Config does not match the schema at overlay.json:2:11 /Port: 0 is less than the minimum 1",
+			},
+		},
+		{
+			name:       "data that is not JSON",
+			configPath: "config.json",
+			files: map[string]string{
+				"config.json": `{"Port": 8000 "Env": "Dev"}`,
+			},
+			expectedErrors: []string{
+				"Error parsing config data config.json invalid character '\"' after object key:value pair at config.json:1:15",
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for name, data := range tc.files {
+				fsys[name] = &fstest.MapFile{Data: []byte(data)}
+			}
+
+			opts := append([]Option{WithSchemaValidation(), WithRetryClientBuilder(httpClientBuilder)}, tc.opts...)
+			c, errs := NewFromFS(fsys, tc.configPath, opts...)
+			if tc.expectedErrors != nil {
+				require.Nil(t, c)
+				require.Equal(t, tc.expectedErrors, errorStrings(errs))
+				return
+			}
+			require.Empty(t, errs)
+			require.Equal(t, 8000, c.Port)
+		})
+	}
+}
+
+func TestLineAndColumn(t *testing.T) {
+	data := []byte("{\n  \"Port\": 8000\n}")
+	testcases := []struct {
+		offset         int
+		expectedLine   int
+		expectedColumn int
+	}{
+		{offset: 0, expectedLine: 1, expectedColumn: 1},
+		{offset: 2, expectedLine: 2, expectedColumn: 1},
+		{offset: 12, expectedLine: 2, expectedColumn: 11},
+		{offset: 100, expectedLine: 3, expectedColumn: 2},
+	}
+	for _, tc := range testcases {
+		line, column := lineAndColumn(data, tc.offset)
+		require.Equal(t, tc.expectedLine, line)
+		require.Equal(t, tc.expectedColumn, column)
+	}
+}
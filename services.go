@@ -22,6 +22,10 @@ type ServiceConfig struct {
 	// use MergedComponentConfig() method to access the config
 	mergedComponentConfigs ComponentConfigs
 
+	// currentAuthKey holds the latest auth key, which an AuthKeyRefresher
+	// may replace while the config is in use, use CurrentAuthKey() to read it
+	currentAuthKey *authKeyHolder
+
 	HTTPClient apiclient.RetryClient `json:"-"`
 }
 
@@ -41,6 +45,16 @@ func (s *ServiceConfig) MergedComponentConfigs() ComponentConfigs {
 	return s.mergedComponentConfigs
 }
 
+// CurrentAuthKey returns the latest auth key for the service, including keys
+// replaced by an AuthKeyRefresher. It is safe for concurrent use.
+func (s *ServiceConfig) CurrentAuthKey() string {
+	if s.currentAuthKey == nil {
+		return s.AuthKey
+	}
+	key, _, _ := s.currentAuthKey.get()
+	return key
+}
+
 // ServicesMap maps the name of a service to its configuration
 type ServicesMap map[string]*ServiceConfig
 
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// ReloadOnSignal reloads the config whenever the process receives one of sigs, SIGHUP when none are given, so
+// operators can trigger a reload with kill -HUP. Reloads run through the same pipeline as file changes: a config
+// that fails to load is reported to WatchSettings.OnError and the current one is kept. Call the returned function
+// to stop handling the signals, it waits for any reload in progress to finish.
+func (w *Watcher) ReloadOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, sigs...)
+
+	stopping := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case sig := <-received:
+				w.options.logger.Info("Reloading config file " + w.configPath + " on " + sig.String())
+				_ = w.Reload() // failures are logged and reported to OnError
+			case <-stopping:
+				return
+			case <-w.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(received)
+			close(stopping)
+		})
+		<-stopped
+	}
+}
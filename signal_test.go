@@ -0,0 +1,30 @@
+//go:build !windows
+
+package config
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher_ReloadOnSignal(t *testing.T) {
+	configPath := newWatchedConfig(t, watchedPort("8000"))
+	w, errs := NewWatcher(configPath, WatchSettings{Interval: -1}, WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+	defer w.Stop()
+
+	stop := w.ReloadOnSignal(syscall.SIGUSR1)
+	replaceFile(t, configPath, watchedPort("9000"))
+	require.Equal(t, 8000, w.Config().Port, "polling is disabled")
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	require.Eventually(t, func() bool {
+		return w.Config().Port == 9000
+	}, time.Second, time.Millisecond)
+
+	stop()
+	stop() // stopping twice is harmless
+}
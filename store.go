@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// Store holds the current Config so that it can be replaced while it is being read. Every Config given to a Store
+// is a snapshot that must not be modified once stored. Readers get copies of it, which they may modify freely, and
+// keep the copy they loaded for the duration of a unit of work. The zero Store holds no config.
+type Store struct {
+	current atomic.Pointer[Config]
+}
+
+// NewStore creates a Store holding c
+func NewStore(c *Config) *Store {
+	s := &Store{}
+	s.Store(c)
+	return s
+}
+
+// Load returns a copy of the current config snapshot, nil when no config was stored. The copy shares the http
+// clients and auth keys of the snapshot.
+func (s *Store) Load() *Config {
+	return s.current.Load().clone()
+}
+
+// snapshot returns the current config snapshot itself, for use within the package
+func (s *Store) snapshot() *Config {
+	return s.current.Load()
+}
+
+// Store replaces the current config with c
+func (s *Store) Store(c *Config) {
+	s.current.Store(c)
+}
+
+// Swap replaces the current config with c and returns the config it replaced, which is no longer shared with readers
+func (s *Store) Swap(c *Config) *Config {
+	return s.current.Swap(c)
+}
+
+// GetServiceConfig returns a copy of the named service configuration of the current config
+func (s *Store) GetServiceConfig(name string) (*ServiceConfig, error) {
+	c := s.snapshot()
+	if c == nil {
+		return nil, fmt.Errorf("unable to locate service configuration for %v: no config loaded", name)
+	}
+	service, err := c.GetServiceConfig(name)
+	if err != nil {
+		return nil, err
+	}
+	return service.clone(), nil
+}
+
+// GetDatabaseConfig returns a copy of the named database configuration of the current config, see
+// Config.GetDatabaseConfig
+func (s *Store) GetDatabaseConfig(name string) (*DatabaseConfig, error) {
+	c := s.snapshot()
+	if c == nil {
+		return nil, fmt.Errorf("unable to locate database configuration for %v: no config loaded", name)
+	}
+	return c.GetDatabaseConfig(name)
+}
+
+// clone returns a deep copy of c, sharing its http clients and auth key holders so that the copy sees refreshed
+// auth keys
+func (c *Config) clone() *Config {
+	if c == nil {
+		return nil
+	}
+	configCopy := *c
+	if c.ServiceConfigs != nil {
+		configCopy.ServiceConfigs = make(ServicesMap, len(c.ServiceConfigs))
+		for name, service := range c.ServiceConfigs {
+			configCopy.ServiceConfigs[name] = service.clone()
+		}
+	}
+	if c.DatabaseConfigs != nil {
+		configCopy.DatabaseConfigs = make(DatabasesMap, len(c.DatabaseConfigs))
+		for name, database := range c.DatabaseConfigs {
+			databaseCopy := *database
+			configCopy.DatabaseConfigs[name] = &databaseCopy
+		}
+	}
+	if c.Options != nil {
+		configCopy.Options = cloneValue(c.Options).(map[string]interface{})
+	}
+	if c.transports != nil {
+		configCopy.transports = append([]*http.Transport{}, c.transports...)
+	}
+	if c.files != nil {
+		configCopy.files = append([]string{}, c.files...)
+	}
+	return &configCopy
+}
+
+// clone returns a deep copy of s sharing its http client and auth key holder
+func (s *ServiceConfig) clone() *ServiceConfig {
+	serviceCopy := *s
+	if s.EndPoints != nil {
+		serviceCopy.EndPoints = make(EndpointMap, len(s.EndPoints))
+		for name, endpoint := range s.EndPoints {
+			endpointCopy := *endpoint
+			serviceCopy.EndPoints[name] = &endpointCopy
+		}
+	}
+	return &serviceCopy
+}
+
+// cloneValue returns a deep copy of a value decoded from JSON
+func cloneValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		valueCopy := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			valueCopy[key] = cloneValue(item)
+		}
+		return valueCopy
+	case []interface{}:
+		valueCopy := make([]interface{}, len(value))
+		for i, item := range value {
+			valueCopy[i] = cloneValue(item)
+		}
+		return valueCopy
+	default:
+		return value
+	}
+}
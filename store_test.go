@@ -0,0 +1,98 @@
+package config
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const storeTestConfig = `{
+  "ServiceConfigs": [{"Name": "ABS", "Url": "https://abs.local"}],
+  "DatabaseConfigs": [{"Name": "DB", "Server": "db.local", "AuthRequired": true, "AuthEnvironmentVariable": "STORE_TEST_DB_PASSWORD"}],
+  "Options": {"Features": {"Beta": ["a"]}}
+}`
+
+func newStoreTestConfig(t *testing.T, data string) *Config {
+	c, errs := NewFromBytes([]byte(data), "", WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+	return c
+}
+
+func TestStore(t *testing.T) {
+	t.Setenv("STORE_TEST_DB_PASSWORD", "secret")
+	first := newStoreTestConfig(t, storeTestConfig)
+	store := NewStore(first)
+	require.Equal(t, first, store.Load())
+
+	// readers get copies, changing them leaves the stored config as it was
+	loaded := store.Load()
+	require.NotSame(t, first, loaded)
+	loaded.Port = 9000
+	loaded.ServiceConfigs["ABS"].URL = "https://changed.local"
+	loaded.Options["Features"].(map[string]interface{})["Beta"].([]interface{})[0] = "b"
+	require.Equal(t, 0, first.Port)
+	require.Equal(t, "https://abs.local", first.ServiceConfigs["ABS"].URL)
+	require.Equal(t, []interface{}{"a"}, first.Options["Features"].(map[string]interface{})["Beta"])
+	require.Same(t, first.ServiceConfigs["ABS"].HTTPClient, loaded.ServiceConfigs["ABS"].HTTPClient, "clients are shared")
+
+	abs, err := store.GetServiceConfig("ABS")
+	require.NoError(t, err)
+	require.Equal(t, "https://abs.local", abs.URL)
+	abs.URL = "https://changed.local"
+	require.Equal(t, "https://abs.local", first.ServiceConfigs["ABS"].URL)
+
+	db, err := store.GetDatabaseConfig("DB")
+	require.NoError(t, err)
+	require.Equal(t, "secret", db.Password)
+	require.Empty(t, first.DatabaseConfigs["DB"].Password, "the stored config is not modified")
+
+	_, err = store.GetServiceConfig("CLAIMS")
+	require.EqualError(t, err, "unable to locate service configuration for CLAIMS")
+
+	second := newStoreTestConfig(t, `{"ServiceConfigs": [{"Name": "CLAIMS"}]}`)
+	require.Same(t, first, store.Swap(second))
+	_, err = store.GetServiceConfig("CLAIMS")
+	require.NoError(t, err)
+	_, err = store.GetDatabaseConfig("DB")
+	require.EqualError(t, err, "unable to locate database configuration for DB")
+}
+
+func TestStore_Empty(t *testing.T) {
+	var store Store
+	require.Nil(t, store.Load())
+	_, err := store.GetServiceConfig("ABS")
+	require.EqualError(t, err, "unable to locate service configuration for ABS: no config loaded")
+	_, err = store.GetDatabaseConfig("DB")
+	require.EqualError(t, err, "unable to locate database configuration for DB: no config loaded")
+}
+
+// TestStore_ConcurrentUse is meant to be run with -race
+func TestStore_ConcurrentUse(t *testing.T) {
+	t.Setenv("STORE_TEST_DB_PASSWORD", "secret")
+	store := NewStore(newStoreTestConfig(t, storeTestConfig))
+	next := newStoreTestConfig(t, storeTestConfig)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				db, err := store.GetDatabaseConfig("DB")
+				require.NoError(t, err)
+				require.Equal(t, "secret", db.Password)
+				_, err = store.GetServiceConfig("ABS")
+				require.NoError(t, err)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 100; j++ {
+			next = store.Swap(next)
+		}
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	cnErrors "github.com/CodeNamor/Common/errors"
+)
+
+// validator collects the rule violations found in a config
+type validator struct {
+	errs []error
+}
+
+// Validate checks the loaded config against the structural rules of validateConfig when WithValidation was given
+func (b *defaultConfigBuilder) Validate() []error {
+	if !b.validation {
+		return nil
+	}
+	b.GetLogger().Trace("Validating config")
+	return validateConfig(b.config, b.customAuthKeys)
+}
+
+// validateConfig checks the rules a config must follow to be usable and returns every violation, each naming the
+// path of the field at fault, e.g. ServiceConfigs.ABS.Url:
+//
+//   - Port is between 1 and 65535
+//   - service and auth service URLs are absolute, with a scheme and a host
+//   - client durations, connection counts and retries are not negative
+//   - MaxIdleConnsPerHost does not exceed a MaxConnsPerHost limit
+//   - endpoint names are unique within their service regardless of case
+//   - services and databases with AuthRequired have a source for their key, services being left out when
+//     customAuthKeys is set since a custom getter may find their keys elsewhere
+//
+// It runs once the component configs are merged so the client rules apply to the settings each service uses.
+func validateConfig(c *Config, customAuthKeys bool) []error {
+	v := &validator{}
+
+	if c.Port < 1 || c.Port > 65535 {
+		v.report("Port", fmt.Sprintf("%v is not a port number between 1 and 65535", c.Port))
+	}
+	if c.AuthServiceConfig.Url != "" {
+		v.checkURL("AuthServiceConfig.Url", c.AuthServiceConfig.Url)
+	}
+	v.checkClient("DefaultComponentConfigs.Client", c.DefaultComponentConfigs.Client, c.DefaultComponentConfigs.Client)
+
+	for _, name := range sortedKeys(c.ServiceConfigs) {
+		service := c.ServiceConfigs[name]
+		path := "ServiceConfigs." + name
+		v.checkURL(path+".Url", service.URL)
+		v.checkClient(path+".ComponentConfigOverrides.Client", service.ComponentConfigOverrides.Client, service.MergedComponentConfigs().Client)
+		v.checkEndpoints(path+".EndPoints", service.EndPoints)
+		if service.AuthRequired && !customAuthKeys && service.AuthEnvironmentVariable == "" &&
+			service.AuthCredentials == (AuthCredentials{}) {
+			v.report(path+".AuthRequired", "the service requires auth but has no AuthEnvironmentVariable or AuthCredentials")
+		}
+	}
+
+	for _, name := range sortedKeys(c.DatabaseConfigs) {
+		database := c.DatabaseConfigs[name]
+		if database.AuthRequired && database.AuthEnvironmentVariable == "" {
+			v.report("DatabaseConfigs."+name+".AuthRequired", "the database requires auth but has no AuthEnvironmentVariable")
+		}
+	}
+
+	return v.errs
+}
+
+// report records a violation of the field at path
+func (v *validator) report(path string, problem string) {
+	v.errs = append(v.errs, &cnErrors.ErrorLog{
+		RootCause: "Invalid config value " + path + ":",
+		Err:       errors.New(problem),
+	})
+}
+
+// checkURL reports a URL that cannot be parsed or lacks a scheme or a host
+func (v *validator) checkURL(path string, rawURL string) {
+	if rawURL == "" {
+		v.report(path, "the URL is empty")
+		return
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		v.report(path, err.Error())
+		return
+	}
+	if u.Scheme == "" || u.Host == "" {
+		v.report(path, fmt.Sprintf("%q is not an absolute URL with a scheme and a host", rawURL))
+	}
+}
+
+// checkClient reports the negative settings of client, the settings given at path, and a merged client allowing more
+// idle connections than connections. The merged client is only checked when client sets either connection count so
+// a default violating the rule is reported once.
+func (v *validator) checkClient(path string, client ClientConfig, merged ClientConfig) {
+	settings := []struct {
+		name  string
+		value int
+	}{
+		{"Timeout", client.Timeout},
+		{"IdleConnTimeout", client.IdleConnTimeout},
+		{"MaxIdleConnsPerHost", client.MaxIdleConnsPerHost},
+		{"MaxConnsPerHost", client.MaxConnsPerHost},
+		{"MaxRetries", client.MaxRetries},
+	}
+	for _, setting := range settings {
+		if setting.value < 0 {
+			v.report(path+"."+setting.name, fmt.Sprintf("%v is negative", setting.value))
+		}
+	}
+
+	if client.MaxIdleConnsPerHost == 0 && client.MaxConnsPerHost == 0 {
+		return
+	}
+	if merged.MaxConnsPerHost > 0 && merged.MaxIdleConnsPerHost > merged.MaxConnsPerHost {
+		v.report(path+".MaxIdleConnsPerHost", fmt.Sprintf("%v exceeds MaxConnsPerHost %v", merged.MaxIdleConnsPerHost, merged.MaxConnsPerHost))
+	}
+}
+
+// checkEndpoints reports endpoint names that only differ by case, entries without a Name or sharing one are rejected
+// when decoding
+func (v *validator) checkEndpoints(path string, endpoints EndpointMap) {
+	seen := make(map[string]string, len(endpoints))
+	for _, name := range sortedKeys(endpoints) {
+		if first, exists := seen[strings.ToLower(name)]; exists {
+			v.report(path, fmt.Sprintf("endpoint names %q and %q differ only by case", first, name))
+			continue
+		}
+		seen[strings.ToLower(name)] = name
+	}
+}
+
+// sortedKeys returns the keys of m in order so violations are reported in a stable order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfig(t *testing.T) {
+	testcases := []struct {
+		name           string
+		data           string
+		opts           []Option
+		expectedErrors []string
+	}{
+		{
+			name: "valid config",
+			opts: []Option{WithAuthKeyGetter(mockKeyGetter{keys: map[string]string{"ABS": "123", "CLAIMS": "456"}})},
+			data: `{
+  "Port": 8000,
+  "AuthServiceConfig": {"Url": "https://auth.local"},
+  "DefaultComponentConfigs": {"Client": {"Timeout": 10, "MaxIdleConnsPerHost": 16, "MaxConnsPerHost": 32}},
+  "ServiceConfigs": [
+    {"Name": "ABS", "Url": "https://abs.local", "AuthRequired": true, "AuthEnvironmentVariable": "ABS_KEY",
+     "EndPoints": [{"Name": "ClaimStatus", "Path": "/claims"}, {"Name": "Members", "Path": "/members"}]},
+    {"Name": "CLAIMS", "Url": "http://claims.local:8080", "AuthRequired": true, "AuthCredentials": {"KeyComponent1": "a"},
+     "ComponentConfigOverrides": {"Client": {"MaxIdleConnsPerHost": 4}}}
+  ],
+  "DatabaseConfigs": [{"Name": "DB", "AuthRequired": true, "AuthEnvironmentVariable": "DB_PASSWORD"}]
+}`,
+		},
+		{
+			name: "every violation is reported",
+			data: `{
+  "Port": 70000,
+  "AuthServiceConfig": {"Url": "auth.local"},
+  "DefaultComponentConfigs": {"Client": {"Timeout": -10, "MaxIdleConnsPerHost": 64, "MaxConnsPerHost": 32}},
+  "ServiceConfigs": [
+    {"Name": "ABS", "Url": "", "AuthRequired": true},
+    {"Name": "CLAIMS", "Url": "https://claims.local",
+     "ComponentConfigOverrides": {"Client": {"IdleConnTimeout": -1, "MaxRetries": -2, "MaxConnsPerHost": 8}},
+     "EndPoints": [{"Name": "ClaimStatus"}, {"Name": "claimstatus"}]},
+    {"Name": "MEMBERS", "Url": "https://members.local/%zz"}
+  ],
+  "DatabaseConfigs": [{"Name": "DB", "AuthRequired": true}]
+}`,
+			expectedErrors: []string{
+				"Invalid config value Port: 70000 is not a port number between 1 and 65535",
+				`Invalid config value AuthServiceConfig.Url: "auth.local" is not an absolute URL with a scheme and a host`,
+				"Invalid config value DefaultComponentConfigs.Client.Timeout: -10 is negative",
+				"Invalid config value DefaultComponentConfigs.Client.MaxIdleConnsPerHost: 64 exceeds MaxConnsPerHost 32",
+				"Invalid config value ServiceConfigs.ABS.Url: the URL is empty",
+				"Invalid config value ServiceConfigs.ABS.AuthRequired: the service requires auth but has no AuthEnvironmentVariable or AuthCredentials",
+				"Invalid config value ServiceConfigs.CLAIMS.ComponentConfigOverrides.Client.IdleConnTimeout: -1 is negative",
+				"Invalid config value ServiceConfigs.CLAIMS.ComponentConfigOverrides.Client.MaxRetries: -2 is negative",
+				"Invalid config value ServiceConfigs.CLAIMS.ComponentConfigOverrides.Client.MaxIdleConnsPerHost: 64 exceeds MaxConnsPerHost 8",
+				`Invalid config value ServiceConfigs.CLAIMS.EndPoints: endpoint names "ClaimStatus" and "claimstatus" differ only by case`,
+				`Invalid config value ServiceConfigs.MEMBERS.Url: parse "https://members.local/%zz": invalid URL escape "%zz"`,
+				"Invalid config value DatabaseConfigs.DB.AuthRequired: the database requires auth but has no AuthEnvironmentVariable",
+			},
+		},
+		{
+			name: "keys from a custom getter",
+			data: `{
+  "Port": 8000,
+  "ServiceConfigs": [{"Name": "ABS", "Url": "https://abs.local", "AuthRequired": true}],
+  "DatabaseConfigs": [{"Name": "DB", "AuthRequired": true}]
+}`,
+			opts: []Option{WithAuthKeyGetter(mockKeyGetter{keys: map[string]string{"ABS": "123"}})},
+			expectedErrors: []string{
+				"Invalid config value DatabaseConfigs.DB.AuthRequired: the database requires auth but has no AuthEnvironmentVariable",
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := append([]Option{WithValidation(), WithRetryClientBuilder(httpClientBuilder)}, tc.opts...)
+			c, errs := NewFromBytes([]byte(tc.data), "", opts...)
+			if tc.expectedErrors != nil {
+				require.Nil(t, c)
+				require.Equal(t, tc.expectedErrors, errorStrings(errs))
+				return
+			}
+			require.Empty(t, errs)
+			require.NotNil(t, c)
+		})
+	}
+}
+
+func TestValidateConfig_IsOptIn(t *testing.T) {
+	data := `{"Port": 70000, "ServiceConfigs": [{"Name": "ABS", "Url": "abs.local"}]}`
+	c, errs := NewFromBytes([]byte(data), "", WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+	require.Equal(t, 70000, c.Port)
+}
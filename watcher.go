@@ -0,0 +1,409 @@
+package config
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	cnErrors "github.com/CodeNamor/Common/errors"
+)
+
+// defaultWatchInterval is how often a Watcher checks the config files for changes
+const defaultWatchInterval = 5 * time.Second
+
+// defaultHistorySize is the number of loaded configs a Watcher keeps for Rollback
+const defaultHistorySize = 10
+
+// defaultRetireAfter is how long requests may keep using a replaced config when its clients have no timeout
+const defaultRetireAfter = time.Minute
+
+// ErrStopped is returned by Reload and Rollback once Stop was called
+var ErrStopped = errors.New("the watcher is stopped")
+
+// WatchSettings control how a Watcher reloads the config
+type WatchSettings struct {
+	// Interval is how often the config file, overlays and CA bundles are checked for changes,
+	// it defaults to 5 seconds. A negative Interval disables polling, the config is then only
+	// reloaded by Reload or ReloadOnSignal.
+	Interval time.Duration
+	// RetireAfter is how long after a reload the idle connections of the replaced config are closed
+	// a second time, once the requests still using it have finished. It defaults to the longest
+	// client timeout, retries included, or a minute when a client has no timeout.
+	RetireAfter time.Duration
+	// HistorySize is the number of successfully loaded configs, the current one included, kept so
+	// that Rollback can return to them. It defaults to 10.
+	HistorySize int
+	// Validate is called with every newly loaded config, after the built-in validation enabled by
+	// WithValidation, a config it returns an error for is not used
+	Validate func(*Config) error
+	// OnError is called whenever a reload fails, the current config is kept
+	OnError func(err error)
+}
+
+// ChangeFn is called with the replaced config, the config replacing it and how they differ
+type ChangeFn func(old, new *Config, diff Diff)
+
+// configChange is a replacement of the config waiting to be passed to the OnChange subscribers
+type configChange struct {
+	old, new *Config
+	diff     Diff
+}
+
+// Watcher keeps a Config loaded from a config file up to date. It polls the config file, its overlays, included
+// files and the CA bundles the config refers to, and when any of them changes it runs the whole loading pipeline
+// again. The new config replaces the current one only when it loads and validates without error.
+type Watcher struct {
+	configPath string
+	opts       []Option
+	settings   WatchSettings
+	// options are the parsed opts, used for the logger
+	options options
+
+	store *Store
+
+	// reloadMu serializes reloads and guards fingerprints, the content of each watched file when last checked
+	reloadMu     sync.Mutex
+	fingerprints map[string]string
+
+	subscribersMu sync.Mutex
+	subscribers   []ChangeFn
+
+	// changesMu guards changes, the changes waiting for the subscribers in the order they were made, and notifying,
+	// set while a goroutine calls the subscribers
+	changesMu sync.Mutex
+	changes   []configChange
+	notifying bool
+
+	// retireMu guards retireTimers, the timers closing the idle connections of replaced configs
+	retireMu     sync.Mutex
+	retireTimers map[*time.Timer]*Config
+
+	// history holds the loaded configs, newest first
+	historyMu sync.Mutex
+	history   []historyEntry
+
+	// ctx is cancelled by Stop, abandoning any reload in progress
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewWatcher loads the config at configPath with opts, as New does, and starts watching it for changes. Call Stop
+// to end the watching.
+func NewWatcher(configPath string, settings WatchSettings, opts ...Option) (*Watcher, []error) {
+	if settings.Interval == 0 {
+		settings.Interval = defaultWatchInterval
+	}
+	if settings.HistorySize <= 0 {
+		settings.HistorySize = defaultHistorySize
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &Watcher{
+		configPath:   configPath,
+		opts:         opts,
+		settings:     settings,
+		options:      newOptions(opts),
+		store:        &Store{},
+		retireTimers: make(map[*time.Timer]*Config),
+		ctx:          ctx,
+		cancel:       cancel,
+		done:         make(chan struct{}),
+	}
+
+	c, errs := w.load()
+	if len(errs) != 0 {
+		cancel()
+		return nil, errs
+	}
+	w.store.Store(c)
+	w.fingerprints = w.fingerprint(c, nil)
+	w.remember(c)
+
+	go w.run()
+	return w, nil
+}
+
+// Config returns a copy of the current config, see Store.Load. It is safe for concurrent use, callers should keep
+// the returned config for the duration of a unit of work rather than calling Config repeatedly. A replaced config
+// stays usable, requests in progress with its clients finish normally.
+func (w *Watcher) Config() *Config {
+	return w.store.Load()
+}
+
+// Store returns the Store holding the current config, for components that only need to read the config
+func (w *Watcher) Store() *Store {
+	return w.store
+}
+
+// OnChange calls fn with copies of the replaced and new configs after every reload that replaces the config, in the
+// order the subscriptions were made. Subscribers are called one change at a time, in the order the changes were
+// made, once the reload is over, so fn may call Reload or Rollback: the change they make is passed on after fn
+// returns. A reload may return before its change is passed on when another change is being passed on. The diff is
+// empty when only the content of a CA bundle changed, the http clients are rebuilt all the same.
+func (w *Watcher) OnChange(fn ChangeFn) {
+	w.subscribersMu.Lock()
+	defer w.subscribersMu.Unlock()
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// Reload loads the config again whether or not the files changed, keeping the current config and returning the
+// error when the new one fails to load or validate. It returns ErrStopped once Stop was called.
+func (w *Watcher) Reload() error {
+	w.reloadMu.Lock()
+	var err error
+	if w.ctx.Err() != nil {
+		err = ErrStopped
+	} else {
+		err = w.reload(w.fingerprint(w.store.snapshot(), nil))
+	}
+	w.reloadMu.Unlock()
+	w.notify()
+	return err
+}
+
+// Stop ends the watching and waits for any reload in progress to be abandoned. The idle connections of replaced
+// configs still waiting to be retired are closed.
+func (w *Watcher) Stop() {
+	w.cancel()
+	<-w.done
+
+	// once a reload in progress is over no reload can swap the config and retire the replaced one
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+	w.retireMu.Lock()
+	defer w.retireMu.Unlock()
+	for timer, old := range w.retireTimers {
+		if timer.Stop() {
+			old.CloseIdleConnections()
+		}
+		delete(w.retireTimers, timer)
+	}
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+
+	if w.settings.Interval < 0 { // polling disabled
+		<-w.ctx.Done()
+		return
+	}
+
+	ticker := time.NewTicker(w.settings.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			w.reloadIfChanged()
+		}
+	}
+}
+
+// reloadIfChanged reloads the config when any watched file changed since it was last checked
+func (w *Watcher) reloadIfChanged() {
+	defer w.notify()
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+
+	fingerprints := w.fingerprint(w.store.snapshot(), nil)
+	if equalFingerprints(fingerprints, w.fingerprints) {
+		return
+	}
+	// remember what was seen so a config that fails to load is not retried until it changes again
+	w.fingerprints = fingerprints
+	_ = w.reload(fingerprints)
+}
+
+// reload loads and validates the config then swaps it in, must be called holding reloadMu. before holds the
+// fingerprints of the watched files taken before loading, so that a file changing during the load is seen as
+// changed by the next check.
+func (w *Watcher) reload(before map[string]string) error {
+	c, errs := w.load()
+	if len(errs) != 0 {
+		err := &cnErrors.ErrorLog{
+			RootCause: "Error reloading config file " + w.configPath + ", keeping the current config:",
+			Err:       errors.Join(errs...),
+		}
+		if w.ctx.Err() == nil { // not stopped
+			w.options.logger.Warn(err.Error())
+			if w.settings.OnError != nil {
+				w.settings.OnError(err)
+			}
+		}
+		return err
+	}
+
+	old := w.store.Swap(c)
+	w.fingerprints = w.fingerprint(c, before)
+	w.remember(c)
+	w.options.logger.Info("Reloaded config file " + w.configPath)
+	w.queueChange(old, c)
+	w.retire(old)
+	return nil
+}
+
+// retire closes the idle connections of the replaced config old. Requests in progress keep their connections, which
+// go back to the idle pool when they finish, so the idle connections are closed again once those requests are over.
+func (w *Watcher) retire(old *Config) {
+	old.CloseIdleConnections()
+
+	retireAfter := w.settings.RetireAfter
+	if retireAfter <= 0 {
+		retireAfter = longestRequest(old)
+	}
+
+	w.retireMu.Lock()
+	defer w.retireMu.Unlock()
+	var timer *time.Timer
+	timer = time.AfterFunc(retireAfter, func() {
+		old.CloseIdleConnections()
+		w.retireMu.Lock()
+		defer w.retireMu.Unlock()
+		delete(w.retireTimers, timer)
+	})
+	w.retireTimers[timer] = old
+}
+
+// longestRequest returns how long a request made with a client of c may take, retries included
+func longestRequest(c *Config) time.Duration {
+	clientConfigs := []ClientConfig{c.DefaultComponentConfigs.Client}
+	for _, serviceConfig := range c.ServiceConfigs {
+		clientConfigs = append(clientConfigs, serviceConfig.MergedComponentConfigs().Client)
+	}
+
+	var longest time.Duration
+	for _, clientConfig := range clientConfigs {
+		if clientConfig.Timeout <= 0 {
+			return defaultRetireAfter
+		}
+		request := time.Duration(clientConfig.Timeout*(clientConfig.MaxRetries+1)) * time.Second
+		if request > longest {
+			longest = request
+		}
+	}
+	return longest
+}
+
+// queueChange records the replacement of old by new for notify, must be called holding reloadMu so changes are
+// queued in the order they are made
+func (w *Watcher) queueChange(old, new *Config) {
+	w.subscribersMu.Lock()
+	subscribed := len(w.subscribers) != 0
+	w.subscribersMu.Unlock()
+	if !subscribed {
+		return
+	}
+
+	w.changesMu.Lock()
+	defer w.changesMu.Unlock()
+	w.changes = append(w.changes, configChange{old: old, new: new, diff: DiffConfigs(old, new)})
+}
+
+// notify calls the OnChange subscribers with the queued changes, must be called without holding reloadMu. When
+// another call is passing changes on, including one up the stack of a subscriber, it passes on the queued changes.
+func (w *Watcher) notify() {
+	w.changesMu.Lock()
+	defer w.changesMu.Unlock()
+	if w.notifying {
+		return
+	}
+	w.notifying = true
+	defer func() { w.notifying = false }()
+
+	for len(w.changes) != 0 {
+		change := w.changes[0]
+		w.changes = w.changes[1:]
+
+		w.changesMu.Unlock()
+		w.subscribersMu.Lock()
+		subscribers := append([]ChangeFn(nil), w.subscribers...)
+		w.subscribersMu.Unlock()
+		for _, fn := range subscribers {
+			fn(change.old.clone(), change.new.clone(), change.diff)
+		}
+		w.changesMu.Lock()
+	}
+}
+
+// load runs the loading pipeline then the Validate setting
+func (w *Watcher) load() (*Config, []error) {
+	c, errs := NewWithContext(w.ctx, w.configPath, w.opts...)
+	if len(errs) != 0 {
+		return nil, errs
+	}
+	if w.settings.Validate != nil {
+		if err := w.settings.Validate(c); err != nil {
+			return nil, []error{cnErrors.WithErrorAndCause(err, "Invalid config")}
+		}
+	}
+	return c, nil
+}
+
+// fingerprint returns the md5 of each file c was loaded from, included files and CA bundles among them, files that
+// cannot be read are recorded as such so they count as changed once they can be read again. Files found in known
+// are given the fingerprint recorded there rather than being read.
+func (w *Watcher) fingerprint(c *Config, known map[string]string) map[string]string {
+	fingerprints := make(map[string]string)
+	if info, err := os.Stat(w.configPath); err == nil && info.IsDir() {
+		// config files added to or removed from the directory change the config too
+		if fingerprint, found := known[w.configPath]; found {
+			fingerprints[w.configPath] = fingerprint
+		} else {
+			files, _ := filepath.Glob(filepath.Join(w.configPath, "*.json"))
+			fingerprints[w.configPath] = strings.Join(files, "\n")
+		}
+	}
+	for _, file := range watchedFiles(c) {
+		if fingerprint, found := known[file]; found {
+			fingerprints[file] = fingerprint
+			continue
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			fingerprints[file] = "unreadable: " + err.Error()
+			continue
+		}
+		fingerprints[file] = fmt.Sprintf("%x", md5.Sum(data))
+	}
+	return fingerprints
+}
+
+// watchedFiles lists the config files c was read from and the resolved CA bundle paths it uses
+func watchedFiles(c *Config) []string {
+	files := append([]string(nil), c.files...)
+
+	caBundlePaths := []string{c.DefaultComponentConfigs.Client.CABundlePath}
+	for _, serviceConfig := range c.ServiceConfigs {
+		caBundlePaths = append(caBundlePaths, serviceConfig.MergedComponentConfigs().Client.CABundlePath)
+	}
+	seen := make(map[string]bool)
+	for _, caBundlePath := range caBundlePaths {
+		resolved := resolveCAPathFromDir(c.dir, caBundlePath)
+		if resolved != "" && !seen[resolved] {
+			seen[resolved] = true
+			files = append(files, resolved)
+		}
+	}
+	return files
+}
+
+func equalFingerprints(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for file, fingerprint := range a {
+		if b[file] != fingerprint {
+			return false
+		}
+	}
+	return true
+}
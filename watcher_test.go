@@ -0,0 +1,356 @@
+package config
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const watchInterval = 5 * time.Millisecond
+
+// errorRecorder collects the errors passed to WatchSettings.OnError
+type errorRecorder struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (r *errorRecorder) record(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs = append(r.errs, err)
+}
+
+func (r *errorRecorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.errs)
+}
+
+// changeRecorder collects the diffs passed to OnChange subscribers
+type changeRecorder struct {
+	mu    sync.Mutex
+	olds  []*Config
+	news  []*Config
+	diffs []Diff
+}
+
+func (r *changeRecorder) record(old, new *Config, diff Diff) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.olds, r.news, r.diffs = append(r.olds, old), append(r.news, new), append(r.diffs, diff)
+}
+
+func (r *changeRecorder) changes() (olds, news []*Config, diffs []Diff) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Config(nil), r.olds...), append([]*Config(nil), r.news...), append([]Diff(nil), r.diffs...)
+}
+
+// newWatchedConfig writes config to a temporary config.json, along with a copy of the example CA bundle, and returns
+// the config path
+func newWatchedConfig(t *testing.T, config string) string {
+	dir := t.TempDir()
+	caBundle, err := os.ReadFile("testdata/example_cabundle.pem")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cabundle.pem"), caBundle, 0o600))
+
+	configPath := filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(configPath, []byte(config), 0o600))
+	return configPath
+}
+
+// replaceFile writes data to a temporary file renamed over file so the watcher never sees a partial write
+func replaceFile(t *testing.T, file string, data string) {
+	tmp := file + ".tmp"
+	require.NoError(t, os.WriteFile(tmp, []byte(data), 0o600))
+	require.NoError(t, os.Rename(tmp, file))
+}
+
+func watchedPort(port string) string {
+	return `{"Port": ` + port + `, "DefaultComponentConfigs": {"Client": {"CABundlePath": "cabundle.pem"}}}`
+}
+
+func TestWatcher_ReloadsOnChange(t *testing.T) {
+	configPath := newWatchedConfig(t, watchedPort("8000"))
+	w, errs := NewWatcher(configPath, WatchSettings{Interval: watchInterval}, WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+	defer w.Stop()
+	require.Equal(t, 8000, w.Config().Port)
+
+	replaceFile(t, configPath, watchedPort("9000"))
+	require.Eventually(t, func() bool {
+		return w.Config().Port == 9000
+	}, time.Second, watchInterval)
+}
+
+func TestWatcher_KeepsConfigOnFailure(t *testing.T) {
+	testcases := []struct {
+		name     string
+		file     string
+		data     string
+		validate func(*Config) error
+	}{
+		{
+			name: "config that does not decode",
+			file: "config.json",
+			data: `{"OopsBadField": 123}`,
+		},
+		{
+			name: "config that does not validate",
+			file: "config.json",
+			data: watchedPort("9000"),
+			validate: func(c *Config) error {
+				if c.Port != 8000 {
+					return errors.New("port must not change")
+				}
+				return nil
+			},
+		},
+		{
+			name: "ca bundle that cannot be loaded",
+			file: "cabundle.pem",
+			data: "not a certificate",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			configPath := newWatchedConfig(t, watchedPort("8000"))
+			recorder := &errorRecorder{}
+			w, errs := NewWatcher(configPath, WatchSettings{
+				Interval: -1,
+				Validate: tc.validate,
+				OnError:  recorder.record,
+			}, WithRetryClientBuilder(httpClientBuilder))
+			require.Empty(t, errs)
+			defer w.Stop()
+			original := w.Config()
+
+			replaceFile(t, filepath.Join(filepath.Dir(configPath), tc.file), tc.data)
+			w.reloadIfChanged()
+			require.Equal(t, 1, recorder.count())
+
+			// the failed files are not reloaded again until they change
+			w.reloadIfChanged()
+			require.Equal(t, 1, recorder.count())
+			require.Equal(t, original.Hash, w.Config().Hash)
+			require.Contains(t, recorder.errs[0].Error(), "keeping the current config")
+		})
+	}
+}
+
+func TestWatcher_Reload(t *testing.T) {
+	configPath := newWatchedConfig(t, watchedPort("8000"))
+	w, errs := NewWatcher(configPath, WatchSettings{Interval: time.Hour}, WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+	defer w.Stop()
+
+	replaceFile(t, configPath, watchedPort("9000"))
+	require.NoError(t, w.Reload())
+	require.Equal(t, 9000, w.Config().Port)
+
+	replaceFile(t, configPath, `{"Port": "nope"}`)
+	require.Error(t, w.Reload())
+	require.Equal(t, 9000, w.Config().Port)
+}
+
+func TestWatcher_ChangeDuringReload(t *testing.T) {
+	configPath := newWatchedConfig(t, watchedPort("8000"))
+	edited := false
+	w, errs := NewWatcher(configPath, WatchSettings{
+		Interval: -1,
+		Validate: func(c *Config) error {
+			// the file changes again once it has been read
+			if c.Port == 9000 && !edited {
+				edited = true
+				replaceFile(t, configPath, watchedPort("9001"))
+			}
+			return nil
+		},
+	}, WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+	defer w.Stop()
+
+	replaceFile(t, configPath, watchedPort("9000"))
+	w.reloadIfChanged()
+	require.True(t, edited)
+	require.Equal(t, 9000, w.Config().Port)
+
+	w.reloadIfChanged()
+	require.Equal(t, 9001, w.Config().Port, "the change made during the reload is picked up")
+}
+
+func TestNewWatcher_InvalidConfig(t *testing.T) {
+	configPath := newWatchedConfig(t, `{"OopsBadField": 123}`)
+	w, errs := NewWatcher(configPath, WatchSettings{})
+	require.Nil(t, w)
+	require.NotEmpty(t, errs)
+}
+
+func TestWatcher_OnChange(t *testing.T) {
+	configPath := newWatchedConfig(t, watchedPort("8000"))
+	w, errs := NewWatcher(configPath, WatchSettings{Interval: time.Hour}, WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+	defer w.Stop()
+
+	recorder := &changeRecorder{}
+	w.OnChange(recorder.record)
+	original := w.Config()
+
+	replaceFile(t, configPath, `{"Port": "nope"}`)
+	require.Error(t, w.Reload())
+	_, _, calls := recorder.changes()
+	require.Empty(t, calls, "failed reloads are not notified")
+
+	replaceFile(t, configPath, watchedPort("9000"))
+	require.NoError(t, w.Reload())
+	olds, news, calls := recorder.changes()
+	require.Len(t, calls, 1)
+	require.Equal(t, original.Hash, olds[0].Hash)
+	require.Equal(t, w.Config().Hash, news[0].Hash)
+	require.Equal(t, []FieldChange{{Field: "Port", Old: 8000, New: 9000}}, calls[0].Fields)
+}
+
+func TestWatcher_OnChangeCallingReload(t *testing.T) {
+	configPath := newWatchedConfig(t, watchedPort("8000"))
+	w, errs := NewWatcher(configPath, WatchSettings{Interval: -1}, WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+	defer w.Stop()
+	original := w.Config()
+
+	ports := []int{}
+	w.OnChange(func(old, new *Config, diff Diff) {
+		ports = append(ports, new.Port)
+		switch new.Port {
+		case 9000:
+			// the subscriber reacts to a change by changing the config again
+			replaceFile(t, configPath, watchedPort("9001"))
+			require.NoError(t, w.Reload())
+		case 9001:
+			require.NoError(t, w.Rollback(original.Hash))
+		}
+	})
+
+	replaceFile(t, configPath, watchedPort("9000"))
+	require.NoError(t, w.Reload())
+	require.Equal(t, []int{9000, 9001, 8000}, ports, "changes made by subscribers are passed on in order")
+	require.Equal(t, 8000, w.Config().Port)
+}
+
+func TestWatcher_RetiresReplacedClients(t *testing.T) {
+	var mu sync.Mutex
+	closed := 0
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			mu.Lock()
+			closed++
+			mu.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+	closedCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return closed
+	}
+
+	configPath := newWatchedConfig(t, watchedPort("8000"))
+	w, errs := NewWatcher(configPath, WatchSettings{Interval: -1, RetireAfter: 50 * time.Millisecond},
+		WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+	defer w.Stop()
+
+	// a request is in progress with the client of the config about to be replaced
+	oldClient := w.Config().DefaultHTTPClient
+	inFlight := make(chan error)
+	go func() {
+		request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		response, err := oldClient.Do(request)
+		if err == nil {
+			response.Body.Close()
+		}
+		inFlight <- err
+	}()
+	<-started
+
+	replaceFile(t, configPath, watchedPort("9000"))
+	require.NoError(t, w.Reload())
+	require.NotSame(t, oldClient, w.Config().DefaultHTTPClient)
+
+	close(release)
+	require.NoError(t, <-inFlight, "the request in progress finishes")
+	require.Zero(t, closedCount(), "the connection goes back to the idle pool")
+
+	require.Eventually(t, func() bool {
+		return closedCount() == 1
+	}, time.Second, time.Millisecond, "the idle connection is closed once the config is retired")
+}
+
+func TestWatcher_StopEndsRetirement(t *testing.T) {
+	configPath := newWatchedConfig(t, watchedPort("8000"))
+	w, errs := NewWatcher(configPath, WatchSettings{Interval: -1, RetireAfter: time.Hour},
+		WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+
+	replaceFile(t, configPath, watchedPort("9000"))
+	require.NoError(t, w.Reload())
+	require.Len(t, w.retireTimers, 1)
+
+	w.Stop()
+	require.Empty(t, w.retireTimers, "the retirement timers are stopped")
+}
+
+func TestWatcher_ReloadAfterStop(t *testing.T) {
+	configPath := newWatchedConfig(t, watchedPort("8000"))
+	w, errs := NewWatcher(configPath, WatchSettings{Interval: -1, RetireAfter: time.Hour},
+		WithRetryClientBuilder(httpClientBuilder))
+	require.Empty(t, errs)
+	original := w.Config()
+	w.Stop()
+
+	replaceFile(t, configPath, watchedPort("9000"))
+	require.ErrorIs(t, w.Reload(), ErrStopped)
+	require.ErrorIs(t, w.Rollback(original.Hash), ErrStopped)
+	require.Equal(t, 8000, w.Config().Port)
+	require.Empty(t, w.retireTimers, "no config is retired after Stop")
+}
+
+func TestLongestRequest(t *testing.T) {
+	testcases := []struct {
+		name     string
+		data     string
+		expected time.Duration
+	}{
+		{
+			name:     "longest timeout with retries",
+			data:     `{"DefaultComponentConfigs": {"Client": {"Timeout": 10}}, "ServiceConfigs": [{"Name": "ABS", "ComponentConfigOverrides": {"Client": {"Timeout": 5, "MaxRetries": 3}}}]}`,
+			expected: 20 * time.Second,
+		},
+		{
+			name:     "client without timeout",
+			data:     `{"DefaultComponentConfigs": {"Client": {"Timeout": 10}}, "ServiceConfigs": [{"Name": "ABS", "ComponentConfigOverrides": {"Client": {"Timeout": -1}}}]}`,
+			expected: defaultRetireAfter,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, errs := NewFromBytes([]byte(tc.data), "", WithRetryClientBuilder(httpClientBuilder))
+			require.Empty(t, errs)
+			require.Equal(t, tc.expected, longestRequest(c))
+		})
+	}
+}